@@ -0,0 +1,210 @@
+// Live pprof is a Golang library to generate and use live profiles.
+// Copyright (C)  2018  Christian Mauduit <ufoot@ufoot.org>
+// Live pprof homepage: https://github.com/ufoot/livepprof
+// Contact author: ufoot@ufoot.org
+
+package archive
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/pprof/profile"
+
+	"github.com/ufoot/livepprof"
+)
+
+const (
+	// timeFormat used in file names, sorts alphabetically.
+	timeFormat = "20060102T150405.000Z"
+	// fileSuffix of archived profiles, they are gzipped protobufs.
+	fileSuffix = ".pb.gz"
+)
+
+// FileExistsError when a profile of the same kind, with the same
+// timestamp, is already archived.
+type FileExistsError struct {
+	// Path of the existing file.
+	Path string
+}
+
+// Error string.
+func (e FileExistsError) Error() string {
+	return "archived profile already exists: " + e.Path
+}
+
+// File is an archived profile.
+type File struct {
+	// Path of the file.
+	Path string
+	// Kind of profile.
+	Kind string
+	// Timestamp of the profile, the same as the Data computed from it.
+	Timestamp time.Time
+	// Size of the file, in bytes.
+	Size int64
+}
+
+// Archive stores raw profiles in a directory, one file per profile,
+// named after the kind of profile and its timestamp. Profiles are
+// gzipped, and old files are removed on the fly, when they are too
+// old or when the archive is too big.
+type Archive struct {
+	dir  string
+	opts opts
+	mu   sync.Mutex
+	now  func() time.Time
+}
+
+var _ livepprof.KindArchiver = &Archive{}
+
+// New archive, storing files in dir, which is created if needed.
+func New(dir string, options ...Option) (*Archive, error) {
+	opts := defaultOpts
+	for _, opt := range options {
+		if err := opt(&opts); err != nil {
+			return nil, err
+		}
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &Archive{dir: dir, opts: opts, now: time.Now}, nil
+}
+
+// Path returns the path of the file for a kind of profile and a timestamp.
+func (a *Archive) Path(kind string, ts time.Time) string {
+	return filepath.Join(a.dir, kind+"-"+ts.UTC().Format(timeFormat)+fileSuffix)
+}
+
+func parseName(name string) (string, time.Time, bool) {
+	if !strings.HasSuffix(name, fileSuffix) {
+		return "", time.Time{}, false
+	}
+	name = strings.TrimSuffix(name, fileSuffix)
+	i := strings.LastIndex(name, "-")
+	if i < 1 {
+		return "", time.Time{}, false
+	}
+	ts, err := time.Parse(timeFormat, name[i+1:])
+	if err != nil {
+		return "", time.Time{}, false
+	}
+	return name[:i], ts, true
+}
+
+// Archives tells whether profiles of a kind are archived, see WithKinds.
+func (a *Archive) Archives(kind string) bool {
+	return a.opts.kinds == nil || a.opts.kinds[kind]
+}
+
+// Archive stores a profile, then removes old files if needed. If there is
+// already a profile of the same kind with the same timestamp, to the
+// millisecond, it is kept, and FileExistsError is returned.
+func (a *Archive) Archive(kind string, ts time.Time, gp *profile.Profile) error {
+	if !a.Archives(kind) {
+		return nil
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	path := a.Path(kind, ts)
+	if _, err := os.Stat(path); err == nil {
+		return FileExistsError{Path: path}
+	}
+
+	// Writing to a temporary file first, so that readers never see
+	// a partial profile.
+	f, err := os.CreateTemp(a.dir, ".tmp-"+kind)
+	if err != nil {
+		return err
+	}
+	err = gp.Write(f)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		_ = os.Remove(f.Name())
+		return err
+	}
+
+	return a.clean()
+}
+
+// Files returns the archived profiles, oldest first.
+func (a *Archive) Files() ([]File, error) {
+	entries, err := os.ReadDir(a.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var ret []File
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		kind, ts, ok := parseName(entry.Name())
+		if !ok {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			// Removed since the directory was read.
+			continue
+		}
+		ret = append(ret, File{
+			Path:      filepath.Join(a.dir, info.Name()),
+			Kind:      kind,
+			Timestamp: ts,
+			Size:      info.Size(),
+		})
+	}
+	sort.SliceStable(ret, func(i, j int) bool {
+		return ret[i].Timestamp.Before(ret[j].Timestamp)
+	})
+	return ret, nil
+}
+
+// Open reads an archived profile, typically using the timestamp of a Data.
+func (a *Archive) Open(kind string, ts time.Time) (*profile.Profile, error) {
+	f, err := os.Open(a.Path(kind, ts))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return profile.Parse(f)
+}
+
+// clean removes files which are too old, then the oldest ones until
+// the archive is small enough.
+func (a *Archive) clean() error {
+	files, err := a.Files()
+	if err != nil {
+		return err
+	}
+
+	var size int64
+	for _, f := range files {
+		size += f.Size
+	}
+	limit := a.now().Add(-a.opts.maxAge)
+	for _, f := range files {
+		if !f.Timestamp.Before(limit) && size <= a.opts.maxSize {
+			break
+		}
+		if err := os.Remove(f.Path); err != nil {
+			return err
+		}
+		size -= f.Size
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+// Live pprof is a Golang library to generate and use live profiles.
+// Copyright (C)  2018  Christian Mauduit <ufoot@ufoot.org>
+// Live pprof homepage: https://github.com/ufoot/livepprof
+// Contact author: ufoot@ufoot.org
+
+package archive
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/pprof/profile"
+	"github.com/stretchr/testify/assert"
+)
+
+func testProfile(value int64) *profile.Profile {
+	fn := &profile.Function{ID: 1, Name: "f", Filename: "f.go"}
+	loc := &profile.Location{ID: 1, Address: 0x1000, Line: []profile.Line{{Function: fn, Line: 1}}}
+	return &profile.Profile{
+		SampleType: []*profile.ValueType{{Type: "cpu", Unit: "nanoseconds"}},
+		PeriodType: &profile.ValueType{Type: "cpu", Unit: "nanoseconds"},
+		Period:     1,
+		Function:   []*profile.Function{fn},
+		Location:   []*profile.Location{loc},
+		Sample:     []*profile.Sample{{Location: []*profile.Location{loc}, Value: []int64{value}}},
+	}
+}
+
+func TestArchive(t *testing.T) {
+	assert := assert.New(t)
+
+	dir := t.TempDir()
+	a, err := New(filepath.Join(dir, "sub"), WithMaxAge(time.Hour), WithKinds("cpu"))
+	assert.Nil(err)
+	now := time.Date(2018, 12, 24, 12, 0, 0, 0, time.UTC)
+	a.now = func() time.Time { return now }
+
+	ts1 := now.Add(-2 * time.Hour)
+	ts2 := now.Add(-time.Minute).Add(123 * time.Millisecond)
+	assert.Nil(a.Archive("cpu", ts1, testProfile(1)))
+	assert.Nil(a.Archive("cpu", ts2, testProfile(2)))
+	assert.Nil(a.Archive("heap", ts2, testProfile(3)))
+	assert.True(a.Archives("cpu"))
+	assert.False(a.Archives("heap"))
+
+	// Same kind, same millisecond, the first profile is kept.
+	assert.Equal(FileExistsError{Path: a.Path("cpu", ts2)}, a.Archive("cpu", ts2.Add(time.Microsecond), testProfile(4)))
+	entries, err := os.ReadDir(filepath.Join(dir, "sub"))
+	assert.Nil(err)
+	for _, entry := range entries {
+		assert.False(strings.HasPrefix(entry.Name(), ".tmp"), "temporary files should be removed")
+	}
+
+	files, err := a.Files()
+	assert.Nil(err)
+	assert.Equal(1, len(files), "old profile and heap should not be there")
+	assert.Equal("cpu", files[0].Kind)
+	assert.True(ts2.Equal(files[0].Timestamp))
+	assert.Equal(filepath.Join(dir, "sub", "cpu-20181224T115900.123Z.pb.gz"), files[0].Path)
+
+	gp, err := a.Open("cpu", ts2)
+	assert.Nil(err)
+	assert.Equal(int64(2), gp.Sample[0].Value[0])
+	_, err = a.Open("cpu", ts1)
+	assert.NotNil(err)
+}
+
+func TestArchiveMaxSize(t *testing.T) {
+	assert := assert.New(t)
+
+	dir := t.TempDir()
+	a, err := New(dir)
+	assert.Nil(err)
+	now := time.Now()
+	for i := 0; i < 10; i++ {
+		assert.Nil(a.Archive("cpu", now.Add(time.Duration(i)*time.Second), testProfile(int64(i))))
+	}
+	files, err := a.Files()
+	assert.Nil(err)
+	assert.Equal(10, len(files))
+
+	a.opts.maxSize = 3 * files[0].Size
+	assert.Nil(a.Archive("cpu", now.Add(time.Minute), testProfile(42)))
+	files, err = a.Files()
+	assert.Nil(err)
+	assert.Equal(3, len(files))
+	gp, err := a.Open("cpu", now.Add(time.Minute))
+	assert.Nil(err)
+	assert.Equal(int64(42), gp.Sample[0].Value[0])
+
+	_, err = New(dir, WithMaxSize(0))
+	assert.NotNil(err)
+	_, err = New(dir, WithMaxAge(0))
+	assert.NotNil(err)
+}
@@ -0,0 +1,68 @@
+// Live pprof is a Golang library to generate and use live profiles.
+// Copyright (C)  2018  Christian Mauduit <ufoot@ufoot.org>
+// Live pprof homepage: https://github.com/ufoot/livepprof
+// Contact author: ufoot@ufoot.org
+
+package archive
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	// defaultMaxAge of archived profiles, older ones are removed.
+	defaultMaxAge = 24 * time.Hour
+	// defaultMaxSize of the archive, in bytes, oldest profiles are removed first.
+	defaultMaxSize = 100 * 1024 * 1024
+)
+
+type opts struct {
+	maxAge  time.Duration
+	maxSize int64
+	kinds   map[string]bool
+}
+
+var defaultOpts = opts{
+	maxAge:  defaultMaxAge,
+	maxSize: defaultMaxSize,
+}
+
+// Option passed when creating the archive.
+type Option func(o *opts) error
+
+// WithMaxAge allows a custom max age to be used. Default is 24 hours.
+// Profiles older than this are removed.
+func WithMaxAge(maxAge time.Duration) Option {
+	return func(o *opts) error {
+		if maxAge <= 0 {
+			return fmt.Errorf("invalid max age: %s", maxAge.String())
+		}
+		o.maxAge = maxAge
+		return nil
+	}
+}
+
+// WithMaxSize allows a custom max size, in bytes, to be used. Default is 100 MiB.
+// When the archive is bigger than this, the oldest profiles are removed.
+func WithMaxSize(maxSize int64) Option {
+	return func(o *opts) error {
+		if maxSize <= 0 {
+			return fmt.Errorf("invalid max size: %d", maxSize)
+		}
+		o.maxSize = maxSize
+		return nil
+	}
+}
+
+// WithKinds restricts the archive to some kinds of profiles, typically
+// "cpu" and "heap". Default is to archive all of them.
+func WithKinds(kinds ...string) Option {
+	return func(o *opts) error {
+		o.kinds = make(map[string]bool, len(kinds))
+		for _, kind := range kinds {
+			o.kinds[kind] = true
+		}
+		return nil
+	}
+}
@@ -0,0 +1,28 @@
+// Live pprof is a Golang library to generate and use live profiles.
+// Copyright (C)  2018  Christian Mauduit <ufoot@ufoot.org>
+// Live pprof homepage: https://github.com/ufoot/livepprof
+// Contact author: ufoot@ufoot.org
+
+package livepprof
+
+import (
+	"time"
+
+	"github.com/google/pprof/profile"
+)
+
+// Archiver stores raw profiles.
+type Archiver interface {
+	// Archive a profile of a given kind (KindCPU, KindHeap...), with the
+	// timestamp of the Data computed from it. The profile must not be modified.
+	Archive(kind string, ts time.Time, gp *profile.Profile) error
+}
+
+// KindArchiver is an archiver which only stores some kinds of profiles.
+// Kinds it does not archive are not collected for it, so that their
+// profiling cost is not paid if nothing else uses them.
+type KindArchiver interface {
+	Archiver
+	// Archives tells whether profiles of a kind are archived.
+	Archives(kind string) bool
+}
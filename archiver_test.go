@@ -0,0 +1,97 @@
+// Live pprof is a Golang library to generate and use live profiles.
+// Copyright (C)  2018  Christian Mauduit <ufoot@ufoot.org>
+// Live pprof homepage: https://github.com/ufoot/livepprof
+// Contact author: ufoot@ufoot.org
+
+package livepprof
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/pprof/profile"
+	"github.com/stretchr/testify/assert"
+)
+
+type testArchiver struct {
+	mu         sync.Mutex
+	timestamps map[string][]time.Time
+}
+
+func (ta *testArchiver) Archive(kind string, ts time.Time, gp *profile.Profile) error {
+	ta.mu.Lock()
+	defer ta.mu.Unlock()
+
+	if ta.timestamps == nil {
+		ta.timestamps = make(map[string][]time.Time)
+	}
+	ta.timestamps[kind] = append(ta.timestamps[kind], ts)
+	return gp.CheckValid()
+}
+
+func TestArchiver(t *testing.T) {
+	assert := assert.New(t)
+
+	archiver := &testArchiver{}
+	lp, err := New(
+		WithFilter("livepprof"),
+		WithErrorHandler(func(err error) { assert.Nil(err) }),
+		WithDelay(time.Second/10),
+		WithArchive(archiver),
+	)
+	assert.Nil(err)
+
+	var heaps []Data
+	done := make(chan struct{})
+	go func() {
+		for heap := range lp.Heap() {
+			heaps = append(heaps, heap)
+		}
+		close(done)
+	}()
+
+	time.Sleep(time.Second)
+	lp.Close()
+	<-done
+
+	archiver.mu.Lock()
+	defer archiver.mu.Unlock()
+	assert.True(len(heaps) > 0)
+	assert.True(len(archiver.timestamps[KindHeap]) >= len(heaps))
+	for i, heap := range heaps {
+		assert.Equal(heap.Timestamp, archiver.timestamps[KindHeap][i])
+	}
+	assert.True(len(archiver.timestamps[KindCPU]) > 0)
+}
+
+// kindArchiver only archives heap profiles.
+type kindArchiver struct {
+	testArchiver
+}
+
+func (ka *kindArchiver) Archives(kind string) bool {
+	return kind == KindHeap
+}
+
+func TestKindArchiver(t *testing.T) {
+	assert := assert.New(t)
+
+	archiver := &kindArchiver{}
+	lp, err := New(
+		WithFilter("livepprof"),
+		WithErrorHandler(func(err error) { assert.Nil(err) }),
+		WithDelay(time.Second/10),
+		WithArchive(archiver),
+	)
+	assert.Nil(err)
+
+	time.Sleep(time.Second)
+	lp.Close()
+
+	archiver.mu.Lock()
+	defer archiver.mu.Unlock()
+	assert.True(len(archiver.timestamps[KindHeap]) > 0)
+	assert.Equal(0, len(archiver.timestamps[KindCPU]), "CPU is neither archived nor read")
+	assert.Equal(0, len(archiver.timestamps[KindGoroutine]), "goroutines are neither archived nor read")
+}
@@ -0,0 +1,54 @@
+// Live pprof is a Golang library to generate and use live profiles.
+// Copyright (C)  2018  Christian Mauduit <ufoot@ufoot.org>
+// Live pprof homepage: https://github.com/ufoot/livepprof
+// Contact author: ufoot@ufoot.org
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/ufoot/livepprof"
+)
+
+// diff prints what changed between two profiles, greater changes first.
+func diff(args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("diff", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "usage: livepprof diff [flags] prev.pb.gz cur.pb.gz\n\nflags:\n")
+		fs.PrintDefaults()
+	}
+	var o options
+	o.register(fs)
+	format := fs.String("format", formatTable, "output format: table, json or csv")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return fmt.Errorf("expected two profiles, got %d", fs.NArg())
+	}
+	if o.limit <= 0 {
+		return fmt.Errorf("invalid limit: %d", o.limit)
+	}
+
+	// All entries are kept, else entries around the limit would
+	// be reported as new or gone, the limit applies to changes.
+	prev, err := o.load(fs.Arg(0), math.MaxInt32)
+	if err != nil {
+		return err
+	}
+	cur, err := o.load(fs.Arg(1), math.MaxInt32)
+	if err != nil {
+		return err
+	}
+	d := livepprof.Diff(prev, cur)
+	if len(d.Changes) > o.limit {
+		d.Changes = d.Changes[:o.limit]
+	}
+	return printDiff(stdout, *format, d)
+}
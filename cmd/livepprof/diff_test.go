@@ -0,0 +1,43 @@
+// Live pprof is a Golang library to generate and use live profiles.
+// Copyright (C)  2018  Christian Mauduit <ufoot@ufoot.org>
+// Live pprof homepage: https://github.com/ufoot/livepprof
+// Contact author: ufoot@ufoot.org
+
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/google/pprof/profile"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiff(t *testing.T) {
+	assert := assert.New(t)
+
+	dir := t.TempDir()
+	prev := writeProfile(t, dir, "prev.pb.gz", map[*profile.Function]int64{fnA: 6, fnB: 6})
+	cur := writeProfile(t, dir, "cur.pb.gz", map[*profile.Function]int64{fnA: 2, fnC: 2})
+
+	var stdout, stderr bytes.Buffer
+	assert.Equal(0, run([]string{"diff", "-format", "csv", "-key", "function", prev, cur}, &stdout, &stderr), stderr.String())
+	assert.Equal(strings.Join([]string{
+		"rank,delta,relative,prev,cur,kind,function,file,line,stack",
+		"1,-3,-1,3,0,gone,github.com/me/mypackage.B,/src/github.com/me/mypackage/b.go,0,",
+		"2,-2,-0.6666666666666666,3,1,updated,github.com/me/mypackage.A,/src/github.com/me/mypackage/a.go,0,",
+		"3,1,0,0,1,new,strings.Index,/go/src/strings/strings.go,0,",
+		"",
+	}, "\n"), stdout.String())
+
+	stdout.Reset()
+	assert.Equal(0, run([]string{"diff", "-limit", "1", prev, cur}, &stdout, &stderr), stderr.String())
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	if assert.Len(lines, 2) {
+		assert.Contains(lines[1], "-100.0%")
+		assert.Contains(lines[1], "gone")
+	}
+
+	assert.Equal(1, run([]string{"diff", prev}, &stdout, &stderr))
+}
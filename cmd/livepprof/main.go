@@ -0,0 +1,68 @@
+// Live pprof is a Golang library to generate and use live profiles.
+// Copyright (C)  2018  Christian Mauduit <ufoot@ufoot.org>
+// Live pprof homepage: https://github.com/ufoot/livepprof
+// Contact author: ufoot@ufoot.org
+
+// Command livepprof aggregates pprof profiles the way the livepprof
+// library does, so that the numbers reported live can be reproduced
+// from archived profiles.
+//
+// Usage:
+//
+//	livepprof top [flags] profile.pb.gz
+//	livepprof diff [flags] prev.pb.gz cur.pb.gz
+//
+// Run a command with -h for its flags.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+// command is a livepprof sub-command.
+type command struct {
+	name  string
+	usage string
+	run   func(args []string, stdout, stderr io.Writer) error
+}
+
+var commands = []command{
+	{name: "top", usage: "print the entries of a profile, greater values first", run: top},
+	{name: "diff", usage: "print what changed between two profiles, greater changes first", run: diff},
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintf(w, "usage: livepprof <command> [flags] [args]\n\ncommands:\n")
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-8s %s\n", c.name, c.usage)
+	}
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	if len(args) < 1 {
+		usage(stderr)
+		return 2
+	}
+	for _, c := range commands {
+		if c.name == args[0] {
+			err := c.run(args[1:], stdout, stderr)
+			if err == flag.ErrHelp {
+				return 0
+			}
+			if err != nil {
+				fmt.Fprintf(stderr, "livepprof %s: %v\n", c.name, err)
+				return 1
+			}
+			return 0
+		}
+	}
+	usage(stderr)
+	return 2
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
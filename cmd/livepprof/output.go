@@ -0,0 +1,147 @@
+// Live pprof is a Golang library to generate and use live profiles.
+// Copyright (C)  2018  Christian Mauduit <ufoot@ufoot.org>
+// Live pprof homepage: https://github.com/ufoot/livepprof
+// Contact author: ufoot@ufoot.org
+
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ufoot/livepprof"
+)
+
+const (
+	formatTable = "table"
+	formatJSON  = "json"
+	formatCSV   = "csv"
+	// formatFolded and formatSVG are flame graphs, only for top.
+	formatFolded = "folded"
+	formatSVG    = "svg"
+)
+
+func printData(w io.Writer, format string, data livepprof.Data) error {
+	switch format {
+	case formatTable:
+		return printTable(w, data)
+	case formatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(data)
+	case formatCSV:
+		return printCSV(w, data)
+	case formatFolded:
+		return livepprof.WriteFolded(w, data)
+	case formatSVG:
+		return livepprof.WriteFlameGraph(w, "livepprof "+data.Timestamp.UTC().Format(time.RFC3339), data)
+	}
+	return fmt.Errorf("unknown format: %s", format)
+}
+
+func printTable(w io.Writer, data livepprof.Data) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(tw, "#\tvalue\tshare\t function\t file\t stack\t\n")
+	for i, entry := range data.Entries {
+		file := entry.Key.File
+		if entry.Key.Line > 0 {
+			file += ":" + strconv.Itoa(entry.Key.Line)
+		}
+		fmt.Fprintf(tw, "%d\t%0.3f\t%0.1f%%\t %s\t %s\t %s\t\n", i+1, entry.Value, 100*entry.Share, entry.Key.Function, file, entry.Key.Stack)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	var dropped float64
+	if data.Total != 0 {
+		dropped = data.Dropped / data.Total
+	}
+	_, err := fmt.Fprintf(w, "total %0.3f, dropped %0.3f (%0.1f%%)\n", data.Total, data.Dropped, 100*dropped)
+	return err
+}
+
+func printCSV(w io.Writer, data livepprof.Data) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"rank", "value", "share", "function", "file", "line", "stack"}); err != nil {
+		return err
+	}
+	for i, entry := range data.Entries {
+		record := []string{
+			strconv.Itoa(i + 1),
+			strconv.FormatFloat(entry.Value, 'f', -1, 64),
+			strconv.FormatFloat(entry.Share, 'f', -1, 64),
+			entry.Key.Function,
+			entry.Key.File,
+			strconv.Itoa(entry.Key.Line),
+			entry.Key.Stack,
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func printDiff(w io.Writer, format string, d livepprof.DataDiff) error {
+	switch format {
+	case formatTable:
+		return printDiffTable(w, d)
+	case formatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(d)
+	case formatCSV:
+		return printDiffCSV(w, d)
+	}
+	return fmt.Errorf("unknown format: %s", format)
+}
+
+func printDiffTable(w io.Writer, d livepprof.DataDiff) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(tw, "#\tdelta\trelative\tprev\tcur\t kind\t function\t file\t stack\t\n")
+	for i, c := range d.Changes {
+		relative := "-"
+		if c.Kind != livepprof.ChangeNew {
+			relative = fmt.Sprintf("%+0.1f%%", c.Relative*100)
+		}
+		file := c.Key.File
+		if c.Key.Line > 0 {
+			file += ":" + strconv.Itoa(c.Key.Line)
+		}
+		fmt.Fprintf(tw, "%d\t%+0.3f\t%s\t%0.3f\t%0.3f\t %s\t %s\t %s\t %s\t\n",
+			i+1, c.Delta, relative, c.Prev, c.Cur, c.Kind, c.Key.Function, file, c.Key.Stack)
+	}
+	return tw.Flush()
+}
+
+func printDiffCSV(w io.Writer, d livepprof.DataDiff) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"rank", "delta", "relative", "prev", "cur", "kind", "function", "file", "line", "stack"}); err != nil {
+		return err
+	}
+	for i, c := range d.Changes {
+		record := []string{
+			strconv.Itoa(i + 1),
+			strconv.FormatFloat(c.Delta, 'f', -1, 64),
+			strconv.FormatFloat(c.Relative, 'f', -1, 64),
+			strconv.FormatFloat(c.Prev, 'f', -1, 64),
+			strconv.FormatFloat(c.Cur, 'f', -1, 64),
+			string(c.Kind),
+			c.Key.Function,
+			c.Key.File,
+			strconv.Itoa(c.Key.Line),
+			c.Key.Stack,
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
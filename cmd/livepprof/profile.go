@@ -0,0 +1,236 @@
+// Live pprof is a Golang library to generate and use live profiles.
+// Copyright (C)  2018  Christian Mauduit <ufoot@ufoot.org>
+// Live pprof homepage: https://github.com/ufoot/livepprof
+// Contact author: ufoot@ufoot.org
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/pprof/profile"
+
+	"github.com/ufoot/livepprof"
+	"github.com/ufoot/livepprof/collector"
+	"github.com/ufoot/livepprof/collector/heap"
+	"github.com/ufoot/livepprof/collector/mutex"
+	"github.com/ufoot/livepprof/objfile"
+)
+
+// patterns is a flag which can be given several times.
+type patterns []string
+
+func (p *patterns) String() string {
+	return strings.Join(*p, ",")
+}
+
+func (p *patterns) Set(value string) error {
+	*p = append(*p, value)
+	return nil
+}
+
+// granularities by name, as given on the command line.
+var granularities = map[string]objfile.Granularity{
+	objfile.GranularityStack.String():    objfile.GranularityStack,
+	objfile.GranularityFunction.String(): objfile.GranularityFunction,
+	objfile.GranularityLine.String():     objfile.GranularityLine,
+	objfile.GranularityPackage.String():  objfile.GranularityPackage,
+	objfile.GranularityFile.String():     objfile.GranularityFile,
+	objfile.GranularityCallers.String():  objfile.GranularityCallers,
+}
+
+const (
+	normalizeAuto   = "auto"
+	normalizeSecond = "second"
+	normalizeNone   = "none"
+)
+
+// options to read a profile and aggregate it, common to all commands.
+type options struct {
+	filter    string
+	include   patterns
+	exclude   patterns
+	limit     int
+	key       string
+	callers   int
+	sample    string
+	normalize string
+	binary    string
+}
+
+func (o *options) register(fs *flag.FlagSet) {
+	fs.StringVar(&o.filter, "filter", "", "aggregate on the first frame which file contains this, like livepprof.WithFilter")
+	fs.Var(&o.include, "include", "aggregate on the first frame which function matches this regexp, can be repeated")
+	fs.Var(&o.exclude, "exclude", "never aggregate on frames which function matches this regexp, can be repeated")
+	fs.IntVar(&o.limit, "limit", 20, "number of entries to keep")
+	fs.StringVar(&o.key, "key", objfile.GranularityStack.String(), "aggregation key: stack, function, line, package, file or callers")
+	fs.IntVar(&o.callers, "callers", 1, "number of callers to keep, with -key callers")
+	fs.StringVar(&o.sample, "sample", "", "sample type to report, default is the profile default")
+	fs.StringVar(&o.normalize, "normalize", normalizeAuto, "report values per second, or none, auto does it for CPU, allocations, mutex and block profiles, as the library")
+	fs.StringVar(&o.binary, "binary", "", "binary to resolve addresses with, default is to use the symbols in the profile")
+}
+
+func (o *options) leafFilter() (*objfile.Filter, error) {
+	if len(o.include) == 0 && len(o.exclude) == 0 {
+		return objfile.NewFilter(o.filter), nil
+	}
+	f := objfile.NewFilter(o.filter)
+	for _, expr := range o.include {
+		p, err := objfile.MatchRegexp(objfile.FieldFunction, expr)
+		if err != nil {
+			return nil, err
+		}
+		f.Include = append(f.Include, p)
+	}
+	for _, expr := range o.exclude {
+		p, err := objfile.MatchRegexp(objfile.FieldFunction, expr)
+		if err != nil {
+			return nil, err
+		}
+		f.Exclude = append(f.Exclude, p)
+	}
+	return f, nil
+}
+
+func (o *options) aggregationKey() (objfile.Key, error) {
+	g, ok := granularities[o.key]
+	if !ok {
+		return objfile.Key{}, fmt.Errorf("unknown key: %s", o.key)
+	}
+	key := objfile.Key{Granularity: g, Callers: o.callers}
+	if !key.Valid() {
+		return objfile.Key{}, fmt.Errorf("invalid key: %s, callers: %d", o.key, o.callers)
+	}
+	return key, nil
+}
+
+// isCPU tells whether gp is a CPU profile.
+func isCPU(gp *profile.Profile) bool {
+	return gp.PeriodType != nil && gp.PeriodType.Type == "cpu"
+}
+
+// sampleIndex returns the index of the sample type to report. By default,
+// it's samples for CPU profiles, as the library, else the one the profile
+// says, else the last one, as go tool pprof does. Profiles without sample
+// types, or without the requested one, are errors.
+func (o *options) sampleIndex(gp *profile.Profile) (int, error) {
+	if len(gp.SampleType) == 0 {
+		return -1, fmt.Errorf("profile has no sample type")
+	}
+	sampleType := o.sample
+	if sampleType == "" && isCPU(gp) {
+		return 0, nil
+	}
+	if sampleType == "" {
+		sampleType = gp.DefaultSampleType
+	}
+	if sampleType == "" {
+		return len(gp.SampleType) - 1, nil
+	}
+	return collector.SampleIndex(gp, sampleType)
+}
+
+// isDelta tells whether the values of a sample type accumulate over the
+// duration of the profile, so that the library reports them per second.
+func isDelta(gp *profile.Profile, index int) bool {
+	if isCPU(gp) {
+		return true
+	}
+	if index < 0 || index >= len(gp.SampleType) {
+		return false
+	}
+	switch sampleType := gp.SampleType[index].Type; sampleType {
+	case mutex.SampleTypeContentions, mutex.SampleTypeDelay:
+		return true
+	default:
+		return heap.IsCumulative(sampleType)
+	}
+}
+
+// factor returns what values of the sample at index are multiplied by.
+// Automatic normalization leaves values as they are if the profile has
+// no duration, as snapshots of cumulative profiles do.
+func (o *options) factor(gp *profile.Profile, index int) (float64, error) {
+	normalize := o.normalize
+	if normalize == normalizeAuto {
+		normalize = normalizeNone
+		if isDelta(gp, index) && gp.DurationNanos > 0 {
+			normalize = normalizeSecond
+		}
+	}
+	switch normalize {
+	case normalizeNone:
+		return 1, nil
+	case normalizeSecond:
+		if gp.DurationNanos <= 0 {
+			return 0, fmt.Errorf("profile has no duration, can not report values per second")
+		}
+		return float64(time.Second) / float64(gp.DurationNanos), nil
+	}
+	return 0, fmt.Errorf("unknown normalization: %s", o.normalize)
+}
+
+func readProfile(path string) (*profile.Profile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return profile.Parse(f)
+}
+
+// load reads a profile, and aggregates it the way the library does,
+// keeping the first limit entries.
+func (o *options) load(path string, limit int) (livepprof.Data, error) {
+	gp, err := readProfile(path)
+	if err != nil {
+		return livepprof.Data{}, err
+	}
+	filter, err := o.leafFilter()
+	if err != nil {
+		return livepprof.Data{}, err
+	}
+	key, err := o.aggregationKey()
+	if err != nil {
+		return livepprof.Data{}, err
+	}
+	index, err := o.sampleIndex(gp)
+	if err != nil {
+		return livepprof.Data{}, err
+	}
+	factor, err := o.factor(gp, index)
+	if err != nil {
+		return livepprof.Data{}, err
+	}
+	if limit <= 0 {
+		return livepprof.Data{}, fmt.Errorf("invalid limit: %d", limit)
+	}
+
+	var resolver objfile.SampleResolver = objfile.NewProfileResolver()
+	if o.binary != "" {
+		of, err := objfile.OpenBinary(o.binary, gp.Mapping)
+		if err != nil {
+			return livepprof.Data{}, err
+		}
+		defer of.Close()
+		resolver = of
+	}
+
+	rawData, err := collector.Aggregate(context.Background(), gp, index, resolver, filter, key)
+	if err != nil {
+		return livepprof.Data{}, err
+	}
+	for k := range rawData {
+		rawData[k] *= factor
+	}
+
+	data := livepprof.NewData(time.Unix(0, gp.TimeNanos), rawData, limit)
+	data.SampleType = gp.SampleType[index].Type
+	return data, nil
+}
@@ -0,0 +1,38 @@
+// Live pprof is a Golang library to generate and use live profiles.
+// Copyright (C)  2018  Christian Mauduit <ufoot@ufoot.org>
+// Live pprof homepage: https://github.com/ufoot/livepprof
+// Contact author: ufoot@ufoot.org
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+)
+
+// top prints the entries of a profile, greater values first.
+func top(args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("top", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "usage: livepprof top [flags] profile.pb.gz\n\nflags:\n")
+		fs.PrintDefaults()
+	}
+	var o options
+	o.register(fs)
+	format := fs.String("format", formatTable, "output format: table, json, csv, or a flame graph, folded or svg")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("expected one profile, got %d", fs.NArg())
+	}
+
+	data, err := o.load(fs.Arg(0), o.limit)
+	if err != nil {
+		return err
+	}
+	return printData(stdout, *format, data)
+}
@@ -0,0 +1,170 @@
+// Live pprof is a Golang library to generate and use live profiles.
+// Copyright (C)  2018  Christian Mauduit <ufoot@ufoot.org>
+// Live pprof homepage: https://github.com/ufoot/livepprof
+// Contact author: ufoot@ufoot.org
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/pprof/profile"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ufoot/livepprof"
+)
+
+// writeProfile writes a CPU profile with symbols, to dir, and returns its path.
+func writeProfile(t *testing.T, dir, name string, values map[*profile.Function]int64) string {
+	main := &profile.Function{ID: 1, Name: "main.main", Filename: "/src/github.com/me/mycommand/main.go"}
+	gp := &profile.Profile{
+		SampleType: []*profile.ValueType{
+			{Type: "samples", Unit: "count"},
+			{Type: "cpu", Unit: "nanoseconds"},
+		},
+		PeriodType:    &profile.ValueType{Type: "cpu", Unit: "nanoseconds"},
+		Period:        10000000,
+		TimeNanos:     time.Date(2018, 1, 2, 3, 4, 5, 0, time.UTC).UnixNano(),
+		DurationNanos: int64(2 * time.Second),
+		Function:      []*profile.Function{main},
+	}
+	mainLoc := &profile.Location{ID: 1, Address: 0x1000, Line: []profile.Line{{Function: main, Line: 10}}}
+	gp.Location = append(gp.Location, mainLoc)
+	for fn, value := range values {
+		gp.Function = append(gp.Function, fn)
+		loc := &profile.Location{ID: uint64(len(gp.Location) + 1), Address: 0x1000 + fn.ID, Line: []profile.Line{{Function: fn, Line: 20}}}
+		gp.Location = append(gp.Location, loc)
+		gp.Sample = append(gp.Sample, &profile.Sample{
+			Location: []*profile.Location{loc, mainLoc},
+			Value:    []int64{value, value * gp.Period},
+		})
+	}
+
+	path := filepath.Join(dir, name)
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := gp.Write(f); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+var (
+	fnA = &profile.Function{ID: 2, Name: "github.com/me/mypackage.A", Filename: "/src/github.com/me/mypackage/a.go"}
+	fnB = &profile.Function{ID: 3, Name: "github.com/me/mypackage.B", Filename: "/src/github.com/me/mypackage/b.go"}
+	fnC = &profile.Function{ID: 4, Name: "strings.Index", Filename: "/go/src/strings/strings.go"}
+)
+
+func TestTop(t *testing.T) {
+	assert := assert.New(t)
+
+	path := writeProfile(t, t.TempDir(), "cpu.pb.gz", map[*profile.Function]int64{fnA: 6, fnB: 4, fnC: 2})
+
+	var stdout, stderr bytes.Buffer
+	assert.Equal(0, run([]string{"top", "-format", "json", "-filter", "mypackage", path}, &stdout, &stderr), stderr.String())
+	var data livepprof.Data
+	assert.Nil(json.Unmarshal(stdout.Bytes(), &data))
+	if assert.Len(data.Entries, 3) {
+		// Values are per second, the profile lasts 2 seconds.
+		assert.Equal("github.com/me/mypackage.A", data.Entries[0].Key.Function)
+		assert.Equal(3.0, data.Entries[0].Value)
+		assert.Equal("github.com/me/mypackage.B", data.Entries[1].Key.Function)
+		assert.Equal(2.0, data.Entries[1].Value)
+		// Not in mypackage, nor its callers, so aggregated on the leaf.
+		assert.Equal("strings.Index", data.Entries[2].Key.Function)
+		assert.Equal(1.0, data.Entries[2].Value)
+	}
+
+	stdout.Reset()
+	assert.Equal(0, run([]string{"top", "-format", "csv", "-limit", "1", "-normalize", "none", "-key", "function", path}, &stdout, &stderr), stderr.String())
+	assert.Equal("rank,value,share,function,file,line,stack\n1,6,0.5,github.com/me/mypackage.A,/src/github.com/me/mypackage/a.go,0,\n", stdout.String())
+
+	stdout.Reset()
+	assert.Equal(0, run([]string{"top", "-exclude", `mypackage\.A$`, path}, &stdout, &stderr), stderr.String())
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	if assert.Len(lines, 5) {
+		assert.Contains(lines[1], "main.main")
+		assert.Contains(lines[1], "3.000")
+		assert.Contains(lines[1], "50.0%")
+		assert.Equal("total 6.000, dropped 0.000 (0.0%)", lines[4])
+	}
+
+	stdout.Reset()
+	assert.Equal(0, run([]string{"top", "-format", "folded", "-normalize", "none", path}, &stdout, &stderr), stderr.String())
+	assert.Equal("main.main;mypackage.A 6\nmain.main;mypackage.B 4\nmain.main;strings.Index 2\n", stdout.String())
+
+	stderr.Reset()
+	assert.Equal(1, run([]string{"top", "-key", "nothing", path}, &stdout, &stderr))
+	assert.Contains(stderr.String(), "unknown key")
+	assert.Equal(2, run([]string{"nothing"}, &stdout, &stderr))
+	assert.Equal(0, run([]string{"top", "-h"}, &stdout, &stderr))
+}
+
+func TestFactor(t *testing.T) {
+	assert := assert.New(t)
+
+	o := options{normalize: normalizeAuto}
+	gp := &profile.Profile{
+		SampleType: []*profile.ValueType{
+			{Type: "contentions", Unit: "count"},
+			{Type: "delay", Unit: "nanoseconds"},
+		},
+		DurationNanos: int64(2 * time.Second),
+	}
+	f, err := o.factor(gp, 1)
+	assert.Nil(err)
+	assert.Equal(0.5, f)
+
+	gp.SampleType = []*profile.ValueType{
+		{Type: "alloc_objects", Unit: "count"},
+		{Type: "alloc_space", Unit: "bytes"},
+		{Type: "inuse_objects", Unit: "count"},
+		{Type: "inuse_space", Unit: "bytes"},
+	}
+	f, err = o.factor(gp, 1)
+	assert.Nil(err)
+	assert.Equal(0.5, f)
+	f, err = o.factor(gp, 3)
+	assert.Nil(err)
+	assert.Equal(1.0, f)
+
+	// Snapshots of cumulative profiles have no duration.
+	gp.DurationNanos = 0
+	f, err = o.factor(gp, 1)
+	assert.Nil(err)
+	assert.Equal(1.0, f)
+	o.normalize = normalizeSecond
+	_, err = o.factor(gp, 1)
+	assert.NotNil(err)
+}
+
+func TestTopEmpty(t *testing.T) {
+	assert := assert.New(t)
+
+	path := filepath.Join(t.TempDir(), "empty.pb.gz")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Nil((&profile.Profile{}).Write(f))
+	assert.Nil(f.Close())
+
+	o := options{key: "stack", normalize: normalizeAuto}
+	_, err = o.load(path, 10)
+	if assert.NotNil(err) {
+		assert.Contains(err.Error(), "no sample type")
+	}
+
+	var stdout, stderr bytes.Buffer
+	assert.Equal(1, run([]string{"top", path}, &stdout, &stderr))
+	assert.Contains(stderr.String(), "no sample type")
+}
@@ -84,6 +84,21 @@ func do() {
 		log.Printf("no more heap profiles")
 	}()
 
+	go func() {
+		log.Printf("ready to log goroutine")
+		for goroutine := range lp.Goroutine() {
+			log.Printf("goroutine timestamp=%v", goroutine.Timestamp)
+			for i, entry := range goroutine.Entries {
+				log.Printf("goroutine %d/%d: %s -> %0.1f",
+					i+1, len(goroutine.Entries),
+					entry.Key.String(),
+					entry.Value,
+				)
+			}
+		}
+		log.Printf("no more goroutine profiles")
+	}()
+
 	time.Sleep(time.Minute)
 	close(exit)
 }
@@ -0,0 +1,195 @@
+// Live pprof is a Golang library to generate and use live profiles.
+// Copyright (C)  2018  Christian Mauduit <ufoot@ufoot.org>
+// Live pprof homepage: https://github.com/ufoot/livepprof
+// Contact author: ufoot@ufoot.org
+
+package block
+
+import (
+	"bytes"
+	"context"
+	"runtime"
+	"runtime/pprof"
+	"sync"
+	"time"
+
+	"github.com/google/pprof/profile"
+
+	"github.com/ufoot/livepprof/collector"
+	"github.com/ufoot/livepprof/objfile"
+)
+
+const (
+	// SampleTypeContentions reports the number of contentions per second.
+	SampleTypeContentions = "contentions"
+	// SampleTypeDelay reports the time spent waiting, in nanoseconds per second.
+	SampleTypeDelay = "delay"
+)
+
+// NoBlockProfileError when there's no block profile.
+type NoBlockProfileError struct{}
+
+// Error string.
+func (e NoBlockProfileError) Error() string {
+	return "no block profile"
+}
+
+// NoLocationError when no location can be found.
+type NoLocationError struct{}
+
+// Error string.
+func (e NoLocationError) Error() string {
+	return "no location"
+}
+
+// DelayTooShortError when the delay is not long enough.
+type DelayTooShortError struct{}
+
+// Error string.
+func (e DelayTooShortError) Error() string {
+	return "delay too short"
+}
+
+// Block contention collector.
+type Block struct {
+	contains string
+	delay    time.Duration
+	rate     int
+	setRate  sync.Once
+	opts     collector.Options
+}
+
+var _ collector.ContextCollector = &Block{}
+
+// New block collector. On average one blocking event per rate nanoseconds
+// spent blocked is reported, see runtime.SetBlockProfileRate. This rate is
+// set once, on the first collection, and is never reset, so the program can
+// still use block profiles for itself. The runtime offers no way to read
+// the current rate, so if the program sets it itself, pass 0 or less, and
+// it is left untouched. Nothing is reported until a rate is set, as block
+// profiling is disabled by default. Default sample type is SampleTypeDelay.
+func New(contains string, delay time.Duration, rate int, options ...collector.Option) *Block {
+	return &Block{
+		contains: contains,
+		delay:    delay,
+		rate:     rate,
+		opts:     collector.NewOptions(options...),
+	}
+}
+
+func snapshot() (*profile.Profile, error) {
+	rp := pprof.Lookup("block")
+	if rp == nil {
+		return nil, NoBlockProfileError{}
+	}
+
+	var buf bytes.Buffer
+
+	err := rp.WriteTo(&buf, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	return profile.Parse(&buf)
+}
+
+// Collect data. Block profiles are cumulative, so two snapshots are taken,
+// at the beginning and at the end of the delay, and the difference is reported.
+func (b *Block) Collect(exit <-chan struct{}) (map[objfile.Location]float64, error) {
+	return b.collect(context.Background(), exit)
+}
+
+// CollectContext collects data, like Collect, but if ctx is done before
+// data is ready, it stops and returns the context error.
+func (b *Block) CollectContext(ctx context.Context) (map[objfile.Location]float64, error) {
+	return b.collect(ctx, nil)
+}
+
+func (b *Block) collect(ctx context.Context, exit <-chan struct{}) (map[objfile.Location]float64, error) {
+	if b.delay <= 0 {
+		return nil, DelayTooShortError{}
+	}
+	if b.rate > 0 {
+		b.setRate.Do(func() {
+			runtime.SetBlockProfileRate(b.rate)
+		})
+	}
+
+	prev, err := snapshot()
+	if err != nil {
+		return nil, err
+	}
+
+	// Wait for b.delay time, but quit earlier if exit is closed.
+	start := time.Now()
+	timer := time.NewTimer(b.delay)
+	select {
+	case <-timer.C:
+	case <-exit:
+		if !timer.Stop() {
+			<-timer.C
+		}
+	case <-ctx.Done():
+		if !timer.Stop() {
+			<-timer.C
+		}
+		return nil, ctx.Err()
+	}
+
+	cur, err := snapshot()
+	if err != nil {
+		return nil, err
+	}
+	delay := time.Now().Sub(start)
+	if delay <= 0 {
+		// This should never happen, but let's not take the risk.
+		delay = time.Millisecond
+	}
+
+	gp, err := collector.Delta(prev, cur)
+	if err != nil {
+		return nil, err
+	}
+
+	if b.opts.Raw != nil {
+		b.opts.Raw(gp)
+	}
+
+	sampleType := b.opts.SampleType
+	if sampleType == "" {
+		sampleType = SampleTypeDelay
+	}
+	index, err := collector.SampleIndex(gp, sampleType)
+	if err != nil {
+		return nil, err
+	}
+
+	resolver, err := objfile.NewSampleResolver(b.opts.Backend)
+	if err != nil {
+		return nil, err
+	}
+	filter := b.opts.LeafFilter(b.contains)
+
+	ret := make(map[objfile.Location]float64)
+	factor := float64(time.Second) / float64(delay)
+	for _, sample := range gp.Sample {
+		if len(sample.Location) < 1 {
+			return nil, NoLocationError{}
+		}
+		d := float64(sample.Value[index])
+		if d <= 0 {
+			// Nothing happened there during the delay, skip before resolving.
+			continue
+		}
+		loc, err := resolver.ResolveSample(ctx, filter, sample)
+		if err != nil {
+			return nil, err
+		}
+		if loc == nil {
+			return nil, NoLocationError{}
+		}
+		ret[b.opts.Key.Apply(*loc)] += d * factor
+	}
+
+	return ret, nil
+}
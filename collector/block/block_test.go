@@ -0,0 +1,77 @@
+// Live pprof is a Golang library to generate and use live profiles.
+// Copyright (C)  2018  Christian Mauduit <ufoot@ufoot.org>
+// Live pprof homepage: https://github.com/ufoot/livepprof
+// Contact author: ufoot@ufoot.org
+
+package block
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ufoot/livepprof/collector"
+)
+
+func waiter(ticks <-chan time.Time, exit <-chan struct{}) {
+	for {
+		select {
+		case <-ticks:
+		case <-exit:
+			return
+		}
+	}
+}
+
+func TestCollect(t *testing.T) {
+	assert := assert.New(t)
+
+	// Collectors set the rate for the whole process, and never reset it.
+	defer runtime.SetBlockProfileRate(0)
+
+	var wg sync.WaitGroup
+	exit := make(chan struct{})
+	ticker := time.NewTicker(time.Millisecond)
+	defer ticker.Stop()
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			waiter(ticker.C, exit)
+		}()
+	}
+
+	b := New("livepprof", time.Second, 1)
+	assert.NotNil(b)
+
+	data, err := b.Collect(nil)
+	assert.Nil(err)
+	assert.NotNil(data)
+
+	assert.True(len(data) > 0, fmt.Sprintf("len(data): %d should be >0", len(data)))
+	for k, v := range data {
+		t.Logf("%s: %0.1f", k.String(), v)
+	}
+
+	b = New("livepprof", time.Second/10, 1, collector.WithSampleType(SampleTypeContentions))
+	data, err = b.Collect(nil)
+	assert.Nil(err)
+	for k, v := range data {
+		t.Logf("%s: %0.1f", k.String(), v)
+	}
+
+	// The program sets the rate itself, the collector leaves it untouched.
+	runtime.SetBlockProfileRate(1)
+	data, err = New("livepprof", time.Second/10, 0).Collect(nil)
+	assert.Nil(err)
+	assert.True(len(data) > 0, fmt.Sprintf("len(data): %d should be >0", len(data)))
+	_, err = New("livepprof", 0, 1).Collect(nil)
+	assert.Equal(DelayTooShortError{}, err)
+
+	close(exit)
+	wg.Wait()
+}
@@ -6,6 +6,8 @@
 package collector
 
 import (
+	"context"
+
 	"github.com/ufoot/livepprof/objfile"
 )
 
@@ -15,3 +17,11 @@ type Collector interface {
 	// Can be interrupted by closing exit chan.
 	Collect(exit <-chan struct{}) (map[objfile.Location]float64, error)
 }
+
+// ContextCollector is a collector which can be cancelled with a context.
+type ContextCollector interface {
+	Collector
+	// CollectContext collects data, and returns a map of values by location.
+	// If ctx is done before data is ready, it returns the context error.
+	CollectContext(ctx context.Context) (map[objfile.Location]float64, error)
+}
@@ -0,0 +1,162 @@
+// Live pprof is a Golang library to generate and use live profiles.
+// Copyright (C)  2018  Christian Mauduit <ufoot@ufoot.org>
+// Live pprof homepage: https://github.com/ufoot/livepprof
+// Contact author: ufoot@ufoot.org
+
+package cpu
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"runtime/pprof"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/ufoot/livepprof/collector"
+)
+
+// busyRetryDelay is how long to wait before retrying, with BusyDefer,
+// when the CPU profiler is used by someone else.
+const busyRetryDelay = time.Second
+
+// BusyError when the CPU profiler is used by someone else, typically
+// a CPU profile started from net/http/pprof.
+type BusyError struct {
+	Err error
+}
+
+// Error string.
+func (e BusyError) Error() string {
+	return "cpu profiler busy: " + e.Err.Error()
+}
+
+// There can only be one CPU profile at a time in a process,
+// pprof.StartCPUProfile fails if one is active, so the state
+// of the profiler is global.
+var (
+	// busy is held while a CPU profile is started by livepprof,
+	// collectors wait for each other instead of failing.
+	busy = make(chan struct{}, 1)
+	// current is the window in progress, if any.
+	current   *window
+	currentMu sync.Mutex
+)
+
+// window is a CPU profile in progress, others can wait for it to be done
+// and share its data, instead of starting their own.
+type window struct {
+	buf      bytes.Buffer
+	start    time.Time
+	done     chan struct{}
+	data     []byte
+	duration time.Duration
+}
+
+func sigProfile() error {
+	pid := os.Getpid()
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return err
+	}
+	return p.Signal(syscall.SIGPROF)
+}
+
+// startWindow starts the CPU profiler. It must be called with busy held.
+func startWindow() (*window, error) {
+	w := &window{done: make(chan struct{})}
+	if err := pprof.StartCPUProfile(&w.buf); err != nil {
+		// This is the only reason why it can fail.
+		return nil, BusyError{Err: err}
+	}
+	if err := sigProfile(); err != nil {
+		pprof.StopCPUProfile()
+		return nil, err
+	}
+	w.start = time.Now()
+
+	currentMu.Lock()
+	current = w
+	currentMu.Unlock()
+
+	return w, nil
+}
+
+// finish stops the CPU profiler, and wakes up those waiting for the window.
+func (w *window) finish() {
+	pprof.StopCPUProfile()
+	w.duration = time.Now().Sub(w.start)
+	if w.duration <= 0 {
+		// This should never happen, but let's not take the risk.
+		w.duration = time.Millisecond
+	}
+	w.data = w.buf.Bytes()
+
+	currentMu.Lock()
+	current = nil
+	currentMu.Unlock()
+
+	close(w.done)
+}
+
+// join returns the window in progress, nil if there is none.
+func join() *window {
+	currentMu.Lock()
+	defer currentMu.Unlock()
+
+	return current
+}
+
+// record runs the CPU profiler for a given delay, but quits earlier if
+// exit is closed, or fails if ctx is done. It returns a nil window if
+// exit is closed before the profile could start. If the profiler is
+// used by someone else, it returns a BusyError, or retries, depending
+// on the policy.
+func record(ctx context.Context, exit <-chan struct{}, delay time.Duration, policy collector.BusyPolicy) (*window, error) {
+	// Wait for any other livepprof profile to be done.
+	select {
+	case busy <- struct{}{}:
+	case <-exit:
+		return nil, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	defer func() { <-busy }()
+
+	var w *window
+	for {
+		var err error
+		w, err = startWindow()
+		if err == nil {
+			break
+		}
+		if _, ok := err.(BusyError); !ok || policy != collector.BusyDefer {
+			return nil, err
+		}
+		retry := time.NewTimer(busyRetryDelay)
+		select {
+		case <-retry.C:
+		case <-exit:
+			retry.Stop()
+			return nil, nil
+		case <-ctx.Done():
+			retry.Stop()
+			return nil, ctx.Err()
+		}
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-exit:
+	case <-ctx.Done():
+		// Others sharing the window still get its data.
+		w.finish()
+		return nil, ctx.Err()
+	}
+	w.finish()
+
+	return w, nil
+}
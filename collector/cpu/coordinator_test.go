@@ -0,0 +1,71 @@
+// Live pprof is a Golang library to generate and use live profiles.
+// Copyright (C)  2018  Christian Mauduit <ufoot@ufoot.org>
+// Live pprof homepage: https://github.com/ufoot/livepprof
+// Contact author: ufoot@ufoot.org
+
+package cpu
+
+import (
+	"bytes"
+	"context"
+	"net/http/httptest"
+	"runtime/pprof"
+	"testing"
+	"time"
+
+	"github.com/google/pprof/profile"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ufoot/livepprof/collector"
+)
+
+func TestBusy(t *testing.T) {
+	assert := assert.New(t)
+
+	// Someone else, not livepprof, is profiling.
+	var buf bytes.Buffer
+	assert.Nil(pprof.StartCPUProfile(&buf))
+
+	_, err := New("livepprof", time.Second/10).Collect(nil)
+	_, ok := err.(BusyError)
+	assert.True(ok, "error should be a BusyError")
+
+	time.AfterFunc(time.Second/2, pprof.StopCPUProfile)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	start := time.Now()
+	data, err := New("livepprof", time.Second/10, collector.WithBusyPolicy(collector.BusyDefer)).CollectContext(ctx)
+	assert.Nil(err)
+	assert.NotNil(data)
+	assert.True(time.Since(start) >= time.Second/2, "should have waited for the other profile")
+}
+
+func TestHandler(t *testing.T) {
+	assert := assert.New(t)
+
+	// A collector is running, the handler shares its window.
+	done := make(chan struct{})
+	go func() {
+		_, err := New("livepprof", time.Second).Collect(nil)
+		assert.Nil(err)
+		close(done)
+	}()
+	time.Sleep(time.Second / 5)
+
+	start := time.Now()
+	rec := httptest.NewRecorder()
+	Handler{}.ServeHTTP(rec, httptest.NewRequest("GET", "/debug/pprof/profile?seconds=60", nil))
+	assert.Equal(200, rec.Code)
+	assert.True(time.Since(start) < 10*time.Second, "should not profile for 60 seconds")
+	gp, err := profile.Parse(rec.Body)
+	assert.Nil(err)
+	assert.True(gp.DurationNanos >= int64(time.Second/2))
+	<-done
+
+	// No collector running, the handler profiles by itself.
+	rec = httptest.NewRecorder()
+	Handler{}.ServeHTTP(rec, httptest.NewRequest("GET", "/debug/pprof/profile?seconds=1", nil))
+	assert.Equal(200, rec.Code)
+	_, err = profile.Parse(rec.Body)
+	assert.Nil(err)
+}
@@ -7,9 +7,7 @@ package cpu
 
 import (
 	"bytes"
-	"os"
-	"runtime/pprof"
-	"syscall"
+	"context"
 	"time"
 
 	"github.com/google/pprof/profile"
@@ -46,80 +44,67 @@ func (e DelayTooShortError) Error() string {
 type CPU struct {
 	contains string
 	delay    time.Duration
+	opts     collector.Options
 }
 
-var _ collector.Collector = &CPU{}
+var _ collector.ContextCollector = &CPU{}
 
 // New CPU collector.
-func New(contains string, delay time.Duration) *CPU {
+func New(contains string, delay time.Duration, options ...collector.Option) *CPU {
 	return &CPU{
 		contains: contains,
 		delay:    delay,
+		opts:     collector.NewOptions(options...),
 	}
 }
 
-func sigProfile() error {
-	pid := os.Getpid()
-	p, err := os.FindProcess(pid)
-	if err != nil {
-		return err
-	}
-	return p.Signal(syscall.SIGPROF)
-}
-
 // Collect data.
 func (c *CPU) Collect(exit <-chan struct{}) (map[objfile.Location]float64, error) {
+	return c.collect(context.Background(), exit)
+}
+
+// CollectContext collects data, like Collect, but if ctx is done before
+// data is ready, it stops and returns the context error.
+func (c *CPU) CollectContext(ctx context.Context) (map[objfile.Location]float64, error) {
+	return c.collect(ctx, nil)
+}
+
+func (c *CPU) collect(ctx context.Context, exit <-chan struct{}) (map[objfile.Location]float64, error) {
 	if c.delay <= 0 {
 		return nil, DelayTooShortError{}
 	}
 
-	var buf bytes.Buffer
-
-	err := pprof.StartCPUProfile(&buf)
-	if err := sigProfile(); err != nil {
-		return nil, err
-	}
+	w, err := record(ctx, exit, c.delay, c.opts.BusyPolicy)
 	if err != nil {
 		return nil, err
 	}
-
-	// Compute a profile for c.delay time, but quit earlier if exit is closed.
-	start := time.Now()
-	timer := time.NewTimer(c.delay)
-	select {
-	case <-timer.C:
-	case <-exit:
-		if !timer.Stop() {
-			<-timer.C
-		}
-	}
-	pprof.StopCPUProfile()
-	delay := time.Now().Sub(start)
-	if delay <= 0 {
-		// This should never happen, but let's not take the risk.
-		delay = time.Millisecond
+	if w == nil {
+		// Interrupted before the profile could start.
+		return make(map[objfile.Location]float64), nil
 	}
+	delay := w.duration
 
-	gp, err := profile.Parse(&buf)
+	gp, err := profile.Parse(bytes.NewReader(w.data))
 	if err != nil {
 		return nil, err
 	}
 
-	objFile, err := objfile.New()
+	if c.opts.Raw != nil {
+		c.opts.Raw(gp)
+	}
+
+	resolver, err := objfile.NewSampleResolver(c.opts.Backend)
 	if err != nil {
 		return nil, err
 	}
+	filter := c.opts.LeafFilter(c.contains)
 	ret := make(map[objfile.Location]float64)
 	factor := float64(time.Second) / float64(delay)
 	for _, sample := range gp.Sample {
 		if len(sample.Location) < 1 {
 			return nil, NoLocationError{}
 		}
-		addresses := make([]uint64, 0, len(sample.Location))
-		for _, loc := range sample.Location {
-			addresses = append(addresses, loc.Address)
-		}
-		loc, err := objFile.Resolve(c.contains, addresses)
+		loc, err := resolver.ResolveSample(ctx, filter, sample)
 		if err != nil {
 			return nil, err
 		}
@@ -132,7 +117,7 @@ func (c *CPU) Collect(exit <-chan struct{}) (map[objfile.Location]float64, error
 		// [TODO:ufoot], really figure out what those numbers are...
 		d := float64(sample.Value[0])
 		if d > 0 {
-			ret[*loc] += d * factor
+			ret[c.opts.Key.Apply(*loc)] += d * factor
 		}
 	}
 
@@ -6,6 +6,7 @@
 package cpu
 
 import (
+	"context"
 	"fmt"
 	"math"
 	"testing"
@@ -73,3 +74,18 @@ func TestCollect(t *testing.T) {
 
 	close(exit)
 }
+
+func TestCollectContext(t *testing.T) {
+	assert := assert.New(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second/10)
+	defer cancel()
+	data, err := New("livepprof", time.Minute).CollectContext(ctx)
+	assert.Equal(context.DeadlineExceeded, err)
+	assert.Nil(data)
+
+	// The profiler must be usable again.
+	data, err = New("livepprof", time.Second/10).CollectContext(context.Background())
+	assert.Nil(err)
+	assert.NotNil(data)
+}
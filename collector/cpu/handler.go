@@ -0,0 +1,58 @@
+// Live pprof is a Golang library to generate and use live profiles.
+// Copyright (C)  2018  Christian Mauduit <ufoot@ufoot.org>
+// Live pprof homepage: https://github.com/ufoot/livepprof
+// Contact author: ufoot@ufoot.org
+
+package cpu
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ufoot/livepprof/collector"
+)
+
+// defaultSeconds is the default duration of a profile, as in net/http/pprof.
+const defaultSeconds = 30
+
+// Handler serves CPU profiles, it can replace /debug/pprof/profile from
+// net/http/pprof. If a livepprof CPU profile is in progress, it waits
+// for it and sends its data, so the window is shared instead of the two
+// fighting over the profiler. Else it starts its own profile for the
+// duration given by the seconds parameter, and collectors wait for it.
+type Handler struct{}
+
+var _ http.Handler = Handler{}
+
+// ServeHTTP serves a CPU profile.
+func (h Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	seconds, err := strconv.ParseInt(r.FormValue("seconds"), 10, 64)
+	if seconds <= 0 || err != nil {
+		seconds = defaultSeconds
+	}
+
+	ctx := r.Context()
+	win := join()
+	if win != nil {
+		select {
+		case <-win.done:
+		case <-ctx.Done():
+			return
+		}
+	} else {
+		win, err = record(ctx, nil, time.Duration(seconds)*time.Second, collector.BusySkip)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			http.Error(w, fmt.Sprintf("Could not enable CPU profiling: %s", err), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", `attachment; filename="profile"`)
+	w.Write(win.data)
+}
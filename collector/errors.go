@@ -0,0 +1,16 @@
+// Live pprof is a Golang library to generate and use live profiles.
+// Copyright (C)  2018  Christian Mauduit <ufoot@ufoot.org>
+// Live pprof homepage: https://github.com/ufoot/livepprof
+// Contact author: ufoot@ufoot.org
+
+package collector
+
+// UnknownSampleTypeError when the profile does not have the sample type.
+type UnknownSampleTypeError struct {
+	SampleType string
+}
+
+// Error string.
+func (e UnknownSampleTypeError) Error() string {
+	return "unknown sample type: " + e.SampleType
+}
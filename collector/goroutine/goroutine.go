@@ -0,0 +1,123 @@
+// Live pprof is a Golang library to generate and use live profiles.
+// Copyright (C)  2018  Christian Mauduit <ufoot@ufoot.org>
+// Live pprof homepage: https://github.com/ufoot/livepprof
+// Contact author: ufoot@ufoot.org
+
+package goroutine
+
+import (
+	"bytes"
+	"context"
+	"runtime/pprof"
+
+	"github.com/google/pprof/profile"
+
+	"github.com/ufoot/livepprof/collector"
+	"github.com/ufoot/livepprof/objfile"
+)
+
+// NoGoroutineProfileError when there's no goroutine profile.
+type NoGoroutineProfileError struct{}
+
+// Error string.
+func (e NoGoroutineProfileError) Error() string {
+	return "no goroutine profile"
+}
+
+// NoLocationError when no location can be found.
+type NoLocationError struct{}
+
+// Error string.
+func (e NoLocationError) Error() string {
+	return "no location"
+}
+
+// UnexpectedValueLenError when the value array does not have expected size.
+type UnexpectedValueLenError struct{}
+
+// Error string.
+func (e UnexpectedValueLenError) Error() string {
+	return "unexpected value len"
+}
+
+// Goroutine collector.
+type Goroutine struct {
+	contains string
+	opts     collector.Options
+}
+
+var _ collector.ContextCollector = &Goroutine{}
+
+// New goroutine collector.
+func New(contains string, options ...collector.Option) *Goroutine {
+	return &Goroutine{
+		contains: contains,
+		opts:     collector.NewOptions(options...),
+	}
+}
+
+// Collect data. Values are the number of goroutines for each location.
+// The goroutine profile is taken at once, so exit is not used.
+func (g *Goroutine) Collect(exit <-chan struct{}) (map[objfile.Location]float64, error) {
+	return g.CollectContext(context.Background())
+}
+
+// CollectContext collects data, like Collect, but if ctx is done before
+// data is ready, it stops and returns the context error.
+func (g *Goroutine) CollectContext(ctx context.Context) (map[objfile.Location]float64, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	rp := pprof.Lookup("goroutine")
+	if rp == nil {
+		return nil, NoGoroutineProfileError{}
+	}
+
+	var buf bytes.Buffer
+
+	err := rp.WriteTo(&buf, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	gp, err := profile.Parse(&buf)
+	if err != nil {
+		return nil, err
+	}
+
+	if g.opts.Raw != nil {
+		g.opts.Raw(gp)
+	}
+
+	resolver, err := objfile.NewSampleResolver(g.opts.Backend)
+	if err != nil {
+		return nil, err
+	}
+	filter := g.opts.LeafFilter(g.contains)
+
+	ret := make(map[objfile.Location]float64)
+	for _, sample := range gp.Sample {
+		if len(sample.Location) < 1 {
+			// Goroutines being created or exiting while the profile
+			// is taken can have an empty stack, just ignore them.
+			continue
+		}
+		loc, err := resolver.ResolveSample(ctx, filter, sample)
+		if err != nil {
+			return nil, err
+		}
+		if loc == nil {
+			return nil, NoLocationError{}
+		}
+		if len(sample.Value) != 1 {
+			return nil, UnexpectedValueLenError{}
+		}
+		d := float64(sample.Value[0])
+		if d > 0 {
+			ret[g.opts.Key.Apply(*loc)] += d
+		}
+	}
+
+	return ret, nil
+}
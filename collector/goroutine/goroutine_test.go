@@ -0,0 +1,53 @@
+// Live pprof is a Golang library to generate and use live profiles.
+// Copyright (C)  2018  Christian Mauduit <ufoot@ufoot.org>
+// Live pprof homepage: https://github.com/ufoot/livepprof
+// Contact author: ufoot@ufoot.org
+
+package goroutine
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func sleeper(ready chan<- struct{}, exit <-chan struct{}) {
+	ready <- struct{}{}
+	<-exit
+}
+
+func TestCollect(t *testing.T) {
+	assert := assert.New(t)
+
+	const n = 10
+
+	ready := make(chan struct{})
+	exit := make(chan struct{})
+	for i := 0; i < n; i++ {
+		go sleeper(ready, exit)
+	}
+	for i := 0; i < n; i++ {
+		<-ready
+	}
+
+	g := New("collector/goroutine")
+	assert.NotNil(g)
+
+	data, err := g.Collect(nil)
+	assert.Nil(err)
+	assert.NotNil(data)
+
+	assert.True(len(data) > 0, fmt.Sprintf("len(data): %d should be >0", len(data)))
+	var found bool
+	for k, v := range data {
+		t.Logf("%s: %0.1f", k.String(), v)
+		if k.Function == "github.com/ufoot/livepprof/collector/goroutine.sleeper" {
+			assert.Equal(float64(n), v)
+			found = true
+		}
+	}
+	assert.True(found, "sleeper goroutines should be reported")
+
+	close(exit)
+}
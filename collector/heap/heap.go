@@ -7,7 +7,10 @@ package heap
 
 import (
 	"bytes"
+	"context"
 	"runtime/pprof"
+	"sync"
+	"time"
 
 	"github.com/google/pprof/profile"
 
@@ -15,6 +18,17 @@ import (
 	"github.com/ufoot/livepprof/objfile"
 )
 
+const (
+	// SampleTypeInuseSpace reports the bytes in use, as of the last GC.
+	SampleTypeInuseSpace = "inuse_space"
+	// SampleTypeInuseObjects reports the objects in use, as of the last GC.
+	SampleTypeInuseObjects = "inuse_objects"
+	// SampleTypeAllocSpace reports the bytes allocated, per second.
+	SampleTypeAllocSpace = "alloc_space"
+	// SampleTypeAllocObjects reports the objects allocated, per second.
+	SampleTypeAllocObjects = "alloc_objects"
+)
+
 // NoHeapProfileError when there's no heap profile.
 type NoHeapProfileError struct{}
 
@@ -34,19 +48,46 @@ func (e NoLocationError) Error() string {
 // Heap collector.
 type Heap struct {
 	contains string
+	opts     collector.Options
+	mu       sync.Mutex
+	prev     *profile.Profile
+	prevTime time.Time
 }
 
-var _ collector.Collector = &Heap{}
+var _ collector.ContextCollector = &Heap{}
+
+// IsCumulative tells wether a sample type is cumulative, that is,
+// counts everything since the program started, and not only what is
+// in use at a given time.
+func IsCumulative(sampleType string) bool {
+	return sampleType == SampleTypeAllocSpace || sampleType == SampleTypeAllocObjects
+}
 
-// New heap collector.
-func New(contains string) *Heap {
+// New heap collector. Default sample type is SampleTypeInuseSpace.
+// Cumulative sample types (alloc_space, alloc_objects) are reported
+// as the difference between two consecutive collections, per second,
+// so that they are comparable to CPU data. As a consequence, the first
+// collection with those returns no data at all.
+func New(contains string, options ...collector.Option) *Heap {
 	return &Heap{
 		contains: contains,
+		opts:     collector.NewOptions(options...),
 	}
 }
 
 // Collect data.
+// The heap profile is taken at once, so exit is not used.
 func (h *Heap) Collect(exit <-chan struct{}) (map[objfile.Location]float64, error) {
+	return h.CollectContext(context.Background())
+}
+
+// CollectContext collects data, like Collect, but if ctx is done before
+// data is ready, it stops and returns the context error.
+func (h *Heap) CollectContext(ctx context.Context) (map[objfile.Location]float64, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	rp := pprof.Lookup("heap")
 	if rp == nil {
 		return nil, NoHeapProfileError{}
@@ -54,7 +95,7 @@ func (h *Heap) Collect(exit <-chan struct{}) (map[objfile.Location]float64, erro
 
 	var buf bytes.Buffer
 
-	err := rp.WriteTo(&buf, 2)
+	err := rp.WriteTo(&buf, 0)
 	if err != nil {
 		return nil, err
 	}
@@ -64,35 +105,66 @@ func (h *Heap) Collect(exit <-chan struct{}) (map[objfile.Location]float64, erro
 		return nil, err
 	}
 
-	objFile, err := objfile.New()
+	sampleType := h.opts.SampleType
+	if sampleType == "" {
+		sampleType = SampleTypeInuseSpace
+	}
+	factor := 1.0
+	if IsCumulative(sampleType) {
+		now := time.Now()
+		h.mu.Lock()
+		prev, prevTime := h.prev, h.prevTime
+		h.prev, h.prevTime = gp, now
+		h.mu.Unlock()
+		if prev == nil {
+			// First call, nothing to compare with.
+			return make(map[objfile.Location]float64), nil
+		}
+		gp, err = collector.Delta(prev, gp)
+		if err != nil {
+			return nil, err
+		}
+		elapsed := now.Sub(prevTime)
+		if elapsed <= 0 {
+			// This should never happen, but let's not take the risk.
+			elapsed = time.Millisecond
+		}
+		factor = float64(time.Second) / float64(elapsed)
+	}
+
+	if h.opts.Raw != nil {
+		h.opts.Raw(gp)
+	}
+
+	index, err := collector.SampleIndex(gp, sampleType)
 	if err != nil {
 		return nil, err
 	}
 
+	resolver, err := objfile.NewSampleResolver(h.opts.Backend)
+	if err != nil {
+		return nil, err
+	}
+	filter := h.opts.LeafFilter(h.contains)
+
 	ret := make(map[objfile.Location]float64)
 	for _, sample := range gp.Sample {
 		if len(sample.Location) < 1 {
 			return nil, NoLocationError{}
 		}
-		addresses := make([]uint64, 0, len(sample.Location))
-		for _, loc := range sample.Location {
-			addresses = append(addresses, loc.Address)
+		d := float64(sample.Value[index])
+		if d <= 0 {
+			// Nothing there, skip before resolving.
+			continue
 		}
-		loc, err := objFile.Resolve(h.contains, addresses)
+		loc, err := resolver.ResolveSample(ctx, filter, sample)
 		if err != nil {
 			return nil, err
 		}
 		if loc == nil {
 			return nil, NoLocationError{}
 		}
-		bytesValues := sample.NumLabel["bytes"]
-		var d float64
-		for _, v := range bytesValues {
-			d += float64(v)
-		}
-		if d > 0 {
-			ret[*loc] += d
-		}
+		ret[h.opts.Key.Apply(*loc)] += d * factor
 	}
 
 	return ret, nil
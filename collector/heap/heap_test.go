@@ -7,10 +7,14 @@ package heap
 
 import (
 	"fmt"
+	"runtime"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+
+	"github.com/ufoot/livepprof/collector"
+	"github.com/ufoot/livepprof/objfile"
 )
 
 func allocator1(n int) []byte {
@@ -41,6 +45,8 @@ func TestCollect(t *testing.T) {
 	buf2b := allocator2(1e6)
 
 	time.Sleep(time.Second / 10)
+	// In use data is reported as of the last GC.
+	runtime.GC()
 
 	h := New("livepprof")
 	assert.NotNil(h)
@@ -58,3 +64,55 @@ func TestCollect(t *testing.T) {
 	assert.Equal(byte(0), buf2a[1])
 	assert.Equal(byte(0), buf2b[1])
 }
+
+func TestCollectProfileBackend(t *testing.T) {
+	assert := assert.New(t)
+
+	// Record every allocation, else buf might not be sampled at all.
+	defer func(rate int) { runtime.MemProfileRate = rate }(runtime.MemProfileRate)
+	runtime.MemProfileRate = 1
+
+	buf := allocator1(1e6)
+	runtime.GC()
+
+	h := New("livepprof", collector.WithBackend(objfile.BackendProfile))
+	assert.NotNil(h)
+
+	data, err := h.Collect(nil)
+	assert.Nil(err)
+	assert.True(len(data) > 0, fmt.Sprintf("len(data): %d should be >0", len(data)))
+	for k, v := range data {
+		t.Logf("%s: %0.1f", k.String(), v)
+	}
+
+	assert.Equal(byte(0), buf[1])
+}
+
+func TestCollectAlloc(t *testing.T) {
+	assert := assert.New(t)
+
+	h := New("livepprof", collector.WithSampleType(SampleTypeAllocSpace))
+	assert.NotNil(h)
+
+	data, err := h.Collect(nil)
+	assert.Nil(err)
+	assert.Equal(0, len(data), "first call has nothing to compare with")
+
+	var bufs [][]byte
+	for i := 0; i < 100; i++ {
+		bufs = append(bufs, allocator2(1e5))
+	}
+	runtime.GC()
+
+	data, err = h.Collect(nil)
+	assert.Nil(err)
+	assert.True(len(data) > 0, fmt.Sprintf("len(data): %d should be >0", len(data)))
+	for k, v := range data {
+		t.Logf("%s: %0.1f", k.String(), v)
+	}
+	assert.Equal(100, len(bufs))
+
+	h = New("livepprof", collector.WithSampleType("nothing"))
+	_, err = h.Collect(nil)
+	assert.Equal(collector.UnknownSampleTypeError{SampleType: "nothing"}, err)
+}
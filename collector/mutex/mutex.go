@@ -0,0 +1,199 @@
+// Live pprof is a Golang library to generate and use live profiles.
+// Copyright (C)  2018  Christian Mauduit <ufoot@ufoot.org>
+// Live pprof homepage: https://github.com/ufoot/livepprof
+// Contact author: ufoot@ufoot.org
+
+package mutex
+
+import (
+	"bytes"
+	"context"
+	"runtime"
+	"runtime/pprof"
+	"time"
+
+	"github.com/google/pprof/profile"
+
+	"github.com/ufoot/livepprof/collector"
+	"github.com/ufoot/livepprof/objfile"
+)
+
+const (
+	// SampleTypeContentions reports the number of contentions per second.
+	SampleTypeContentions = "contentions"
+	// SampleTypeDelay reports the time spent waiting, in nanoseconds per second.
+	SampleTypeDelay = "delay"
+)
+
+// NoMutexProfileError when there's no mutex profile.
+type NoMutexProfileError struct{}
+
+// Error string.
+func (e NoMutexProfileError) Error() string {
+	return "no mutex profile"
+}
+
+// NoLocationError when no location can be found.
+type NoLocationError struct{}
+
+// Error string.
+func (e NoLocationError) Error() string {
+	return "no location"
+}
+
+// DelayTooShortError when the delay is not long enough.
+type DelayTooShortError struct{}
+
+// Error string.
+func (e DelayTooShortError) Error() string {
+	return "delay too short"
+}
+
+// InvalidFractionError when the fraction is not valid.
+type InvalidFractionError struct{}
+
+// Error string.
+func (e InvalidFractionError) Error() string {
+	return "invalid fraction"
+}
+
+// Mutex contention collector.
+type Mutex struct {
+	contains string
+	delay    time.Duration
+	fraction int
+	opts     collector.Options
+}
+
+var _ collector.ContextCollector = &Mutex{}
+
+// New mutex collector. On average 1/fraction of contention events are
+// reported, see runtime.SetMutexProfileFraction. This fraction is only
+// set during collection, and the previous value is restored afterwards.
+// Default sample type is SampleTypeDelay.
+func New(contains string, delay time.Duration, fraction int, options ...collector.Option) *Mutex {
+	return &Mutex{
+		contains: contains,
+		delay:    delay,
+		fraction: fraction,
+		opts:     collector.NewOptions(options...),
+	}
+}
+
+func snapshot() (*profile.Profile, error) {
+	rp := pprof.Lookup("mutex")
+	if rp == nil {
+		return nil, NoMutexProfileError{}
+	}
+
+	var buf bytes.Buffer
+
+	err := rp.WriteTo(&buf, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	return profile.Parse(&buf)
+}
+
+// Collect data. Mutex profiles are cumulative, so two snapshots are taken,
+// at the beginning and at the end of the delay, and the difference is reported.
+func (m *Mutex) Collect(exit <-chan struct{}) (map[objfile.Location]float64, error) {
+	return m.collect(context.Background(), exit)
+}
+
+// CollectContext collects data, like Collect, but if ctx is done before
+// data is ready, it stops and returns the context error.
+func (m *Mutex) CollectContext(ctx context.Context) (map[objfile.Location]float64, error) {
+	return m.collect(ctx, nil)
+}
+
+func (m *Mutex) collect(ctx context.Context, exit <-chan struct{}) (map[objfile.Location]float64, error) {
+	if m.delay <= 0 {
+		return nil, DelayTooShortError{}
+	}
+	if m.fraction <= 0 {
+		return nil, InvalidFractionError{}
+	}
+
+	prevFraction := runtime.SetMutexProfileFraction(m.fraction)
+	defer runtime.SetMutexProfileFraction(prevFraction)
+
+	prev, err := snapshot()
+	if err != nil {
+		return nil, err
+	}
+
+	// Wait for m.delay time, but quit earlier if exit is closed.
+	start := time.Now()
+	timer := time.NewTimer(m.delay)
+	select {
+	case <-timer.C:
+	case <-exit:
+		if !timer.Stop() {
+			<-timer.C
+		}
+	case <-ctx.Done():
+		if !timer.Stop() {
+			<-timer.C
+		}
+		return nil, ctx.Err()
+	}
+
+	cur, err := snapshot()
+	if err != nil {
+		return nil, err
+	}
+	delay := time.Now().Sub(start)
+	if delay <= 0 {
+		// This should never happen, but let's not take the risk.
+		delay = time.Millisecond
+	}
+
+	gp, err := collector.Delta(prev, cur)
+	if err != nil {
+		return nil, err
+	}
+
+	if m.opts.Raw != nil {
+		m.opts.Raw(gp)
+	}
+
+	sampleType := m.opts.SampleType
+	if sampleType == "" {
+		sampleType = SampleTypeDelay
+	}
+	index, err := collector.SampleIndex(gp, sampleType)
+	if err != nil {
+		return nil, err
+	}
+
+	resolver, err := objfile.NewSampleResolver(m.opts.Backend)
+	if err != nil {
+		return nil, err
+	}
+	filter := m.opts.LeafFilter(m.contains)
+
+	ret := make(map[objfile.Location]float64)
+	factor := float64(time.Second) / float64(delay)
+	for _, sample := range gp.Sample {
+		if len(sample.Location) < 1 {
+			return nil, NoLocationError{}
+		}
+		d := float64(sample.Value[index])
+		if d <= 0 {
+			// Nothing happened there during the delay, skip before resolving.
+			continue
+		}
+		loc, err := resolver.ResolveSample(ctx, filter, sample)
+		if err != nil {
+			return nil, err
+		}
+		if loc == nil {
+			return nil, NoLocationError{}
+		}
+		ret[m.opts.Key.Apply(*loc)] += d * factor
+	}
+
+	return ret, nil
+}
@@ -0,0 +1,86 @@
+// Live pprof is a Golang library to generate and use live profiles.
+// Copyright (C)  2018  Christian Mauduit <ufoot@ufoot.org>
+// Live pprof homepage: https://github.com/ufoot/livepprof
+// Contact author: ufoot@ufoot.org
+
+package mutex
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ufoot/livepprof/collector"
+)
+
+func contender(mu *sync.Mutex, exit <-chan struct{}) {
+	for {
+		select {
+		case <-exit:
+			return
+		default: // non-blocking
+		}
+		mu.Lock()
+		time.Sleep(time.Millisecond)
+		mu.Unlock()
+	}
+}
+
+func TestCollect(t *testing.T) {
+	assert := assert.New(t)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	exit := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			contender(&mu, exit)
+		}()
+	}
+
+	prevFraction := runtime.SetMutexProfileFraction(-1)
+
+	m := New("livepprof", time.Second, 1)
+	assert.NotNil(m)
+
+	data, err := m.Collect(nil)
+	assert.Nil(err)
+	assert.NotNil(data)
+
+	assert.True(len(data) > 0, fmt.Sprintf("len(data): %d should be >0", len(data)))
+	for k, v := range data {
+		t.Logf("%s: %0.1f", k.String(), v)
+	}
+	assert.Equal(prevFraction, runtime.SetMutexProfileFraction(-1), "fraction should be restored")
+
+	m = New("livepprof", time.Second/10, 1, collector.WithSampleType(SampleTypeContentions))
+	data, err = m.Collect(nil)
+	assert.Nil(err)
+	for k, v := range data {
+		t.Logf("%s: %0.1f", k.String(), v)
+	}
+
+	m = New("livepprof", time.Second/10, 1, collector.WithSampleType("nothing"))
+	_, err = m.Collect(nil)
+	assert.Equal(collector.UnknownSampleTypeError{SampleType: "nothing"}, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second/10)
+	defer cancel()
+	_, err = New("livepprof", time.Minute, 1).CollectContext(ctx)
+	assert.Equal(context.DeadlineExceeded, err)
+
+	_, err = New("livepprof", time.Second, 0).Collect(nil)
+	assert.Equal(InvalidFractionError{}, err)
+	_, err = New("livepprof", 0, 1).Collect(nil)
+	assert.Equal(DelayTooShortError{}, err)
+
+	close(exit)
+	wg.Wait()
+}
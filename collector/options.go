@@ -0,0 +1,114 @@
+// Live pprof is a Golang library to generate and use live profiles.
+// Copyright (C)  2018  Christian Mauduit <ufoot@ufoot.org>
+// Live pprof homepage: https://github.com/ufoot/livepprof
+// Contact author: ufoot@ufoot.org
+
+package collector
+
+import (
+	"github.com/google/pprof/profile"
+
+	"github.com/ufoot/livepprof/objfile"
+)
+
+// BusyPolicy tells what a collector does when the profiler it needs is
+// already used by someone else, typically a CPU profile started from
+// net/http/pprof.
+type BusyPolicy int
+
+const (
+	// BusySkip returns an error at once, the window is lost.
+	BusySkip BusyPolicy = iota
+	// BusyDefer retries until the profiler is free, or the collection
+	// is cancelled.
+	BusyDefer
+)
+
+// Options shared by all collectors.
+type Options struct {
+	// Backend used to resolve addresses to locations.
+	Backend objfile.Backend
+	// SampleType to report, for profiles which have several values
+	// per sample. Empty means the collector default.
+	SampleType string
+	// Raw is called with the profile data is computed from, if not nil.
+	Raw func(gp *profile.Profile)
+	// BusyPolicy when the profiler is used by someone else.
+	BusyPolicy BusyPolicy
+	// Filter used to find the leaf on which to aggregate data.
+	// If nil, it is built from the string passed to the collector.
+	Filter *objfile.Filter
+	// Key used to aggregate data, the zero value keeps the full stack.
+	Key objfile.Key
+}
+
+// Option passed when creating a collector.
+type Option func(o *Options)
+
+// NewOptions returns the default options, overridden by the given ones.
+func NewOptions(options ...Option) Options {
+	var o Options
+	for _, option := range options {
+		option(&o)
+	}
+	return o
+}
+
+// WithBackend sets the backend used to resolve addresses.
+// Default is objfile.BackendAuto.
+func WithBackend(backend objfile.Backend) Option {
+	return func(o *Options) {
+		o.Backend = backend
+	}
+}
+
+// WithSampleType sets the sample type to report, for instance "delay"
+// or "contentions" for mutex and block profiles. Each collector
+// documents the types it supports, and its default.
+func WithSampleType(sampleType string) Option {
+	return func(o *Options) {
+		o.SampleType = sampleType
+	}
+}
+
+// WithRawHandler sets a func which is called, on each collection, with
+// the profile data is computed from. For cumulative profiles, this is
+// the difference between two snapshots, not the snapshot itself.
+// The profile must not be modified.
+func WithRawHandler(raw func(gp *profile.Profile)) Option {
+	return func(o *Options) {
+		o.Raw = raw
+	}
+}
+
+// WithBusyPolicy sets what to do when the profiler is used by someone else.
+// Default is BusySkip.
+func WithBusyPolicy(policy BusyPolicy) Option {
+	return func(o *Options) {
+		o.BusyPolicy = policy
+	}
+}
+
+// WithFilter sets the filter used to find the leaf on which to aggregate
+// data, it replaces the string passed to the collector.
+func WithFilter(filter *objfile.Filter) Option {
+	return func(o *Options) {
+		o.Filter = filter
+	}
+}
+
+// LeafFilter returns the filter used to find the leaf on which to
+// aggregate data, the one set by WithFilter, else NewFilter(contains).
+func (o Options) LeafFilter(contains string) *objfile.Filter {
+	if o.Filter != nil {
+		return o.Filter
+	}
+	return objfile.NewFilter(contains)
+}
+
+// WithKey sets how data is aggregated, see objfile.Key.
+func WithKey(key objfile.Key) Option {
+	return func(o *Options) {
+		o.Key = key
+	}
+}
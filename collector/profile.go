@@ -0,0 +1,66 @@
+// Live pprof is a Golang library to generate and use live profiles.
+// Copyright (C)  2018  Christian Mauduit <ufoot@ufoot.org>
+// Live pprof homepage: https://github.com/ufoot/livepprof
+// Contact author: ufoot@ufoot.org
+
+package collector
+
+import (
+	"context"
+
+	"github.com/google/pprof/profile"
+
+	"github.com/ufoot/livepprof/objfile"
+)
+
+// SampleIndex returns the index of a sample type in profile values.
+func SampleIndex(gp *profile.Profile, sampleType string) (int, error) {
+	for i, st := range gp.SampleType {
+		if st.Type == sampleType {
+			return i, nil
+		}
+	}
+	return -1, UnknownSampleTypeError{SampleType: sampleType}
+}
+
+// Delta returns the difference between two snapshots of a cumulative
+// profile, that is, what happened between prev and cur. Samples which
+// did not change have a zero value. Neither prev nor cur are modified.
+// The delta starts at prev, and lasts until cur, when both have a time.
+func Delta(prev, cur *profile.Profile) (*profile.Profile, error) {
+	neg := prev.Copy()
+	neg.Scale(-1)
+	delta, err := profile.Merge([]*profile.Profile{cur, neg})
+	if err != nil {
+		return nil, err
+	}
+	if prev.TimeNanos > 0 && cur.TimeNanos > prev.TimeNanos {
+		delta.TimeNanos = prev.TimeNanos
+		delta.DurationNanos = cur.TimeNanos - prev.TimeNanos
+	}
+	return delta, nil
+}
+
+// Aggregate resolves the samples of a profile, and sums their values,
+// for a given sample index, by location. This is what collectors do with
+// the profiles they take, it can be used on profiles of other programs,
+// with a resolver from objfile.OpenBinary, or objfile.NewProfileResolver
+// if they have symbols. Samples with no value or no location are skipped.
+func Aggregate(ctx context.Context, gp *profile.Profile, index int, resolver objfile.SampleResolver, filter *objfile.Filter, key objfile.Key) (map[objfile.Location]float64, error) {
+	ret := make(map[objfile.Location]float64)
+	for _, sample := range gp.Sample {
+		if len(sample.Location) < 1 || index < 0 || index >= len(sample.Value) {
+			continue
+		}
+		d := float64(sample.Value[index])
+		if d == 0 {
+			continue
+		}
+		loc, err := resolver.ResolveSample(ctx, filter, sample)
+		if err != nil {
+			return nil, err
+		}
+		ret[key.Apply(*loc)] += d
+	}
+	return ret, nil
+}
@@ -0,0 +1,85 @@
+// Live pprof is a Golang library to generate and use live profiles.
+// Copyright (C)  2018  Christian Mauduit <ufoot@ufoot.org>
+// Live pprof homepage: https://github.com/ufoot/livepprof
+// Contact author: ufoot@ufoot.org
+
+package collector
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/pprof/profile"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ufoot/livepprof/objfile"
+)
+
+func testProfile(values ...int64) *profile.Profile {
+	fn := &profile.Function{ID: 1, Name: "f", Filename: "f.go"}
+	loc := &profile.Location{ID: 1, Address: 0x1000, Line: []profile.Line{{Function: fn, Line: 1}}}
+	gp := &profile.Profile{
+		SampleType: []*profile.ValueType{
+			{Type: "contentions", Unit: "count"},
+			{Type: "delay", Unit: "nanoseconds"},
+		},
+		PeriodType: &profile.ValueType{Type: "contentions", Unit: "count"},
+		Period:     1,
+		Function:   []*profile.Function{fn},
+		Location:   []*profile.Location{loc},
+	}
+	if len(values) > 0 {
+		gp.Sample = []*profile.Sample{{Location: []*profile.Location{loc}, Value: values}}
+	}
+	return gp
+}
+
+func TestSampleIndex(t *testing.T) {
+	assert := assert.New(t)
+
+	gp := testProfile()
+	i, err := SampleIndex(gp, "delay")
+	assert.Nil(err)
+	assert.Equal(1, i)
+	i, err = SampleIndex(gp, "contentions")
+	assert.Nil(err)
+	assert.Equal(0, i)
+	_, err = SampleIndex(gp, "nothing")
+	assert.Equal(UnknownSampleTypeError{SampleType: "nothing"}, err)
+}
+
+func TestDelta(t *testing.T) {
+	assert := assert.New(t)
+
+	prev := testProfile(3, 100)
+	prev.TimeNanos = 1e9
+	prev.DurationNanos = 5e8
+	cur := testProfile(5, 250)
+	cur.TimeNanos = 4e9
+	cur.DurationNanos = 5e8
+
+	delta, err := Delta(prev, cur)
+	assert.Nil(err)
+	assert.Equal(int64(1e9), delta.TimeNanos)
+	assert.Equal(int64(3e9), delta.DurationNanos)
+	assert.Equal(1, len(delta.Sample))
+	assert.Equal([]int64{2, 150}, delta.Sample[0].Value)
+	assert.Equal([]int64{3, 100}, prev.Sample[0].Value, "prev must not be modified")
+	assert.Equal([]int64{5, 250}, cur.Sample[0].Value, "cur must not be modified")
+}
+
+func TestAggregate(t *testing.T) {
+	assert := assert.New(t)
+
+	gp := testProfile(3, 100)
+	gp.Sample = append(gp.Sample, &profile.Sample{Location: gp.Sample[0].Location, Value: []int64{2, 50}})
+	gp.Sample = append(gp.Sample, &profile.Sample{Value: []int64{1, 1}})
+
+	data, err := Aggregate(context.Background(), gp, 1, objfile.NewProfileResolver(), nil, objfile.Key{})
+	assert.Nil(err)
+	assert.Equal(map[objfile.Location]float64{{Function: "f", File: "f.go", Stack: "f", Frames: objfile.NewFrames([]objfile.Frame{{Package: "f", Function: "f", File: "f.go"}})}: 150}, data)
+
+	data, err = Aggregate(context.Background(), gp, 0, objfile.NewProfileResolver(), nil, objfile.Key{Granularity: objfile.GranularityLine})
+	assert.Nil(err)
+	assert.Equal(map[objfile.Location]float64{{Function: "f", File: "f.go", Line: 1}: 5}, data)
+}
@@ -0,0 +1,168 @@
+// Live pprof is a Golang library to generate and use live profiles.
+// Copyright (C)  2018  Christian Mauduit <ufoot@ufoot.org>
+// Live pprof homepage: https://github.com/ufoot/livepprof
+// Contact author: ufoot@ufoot.org
+
+"use strict";
+
+(function () {
+  var kind = new URLSearchParams(window.location.search).get("kind") || "";
+  // Stacks which are expanded, by key, kept across refreshes.
+  var expanded = {};
+  var timer = null;
+
+  function el(name, attrs, text) {
+    var e = document.createElement(name);
+    for (var k in attrs || {}) {
+      e.setAttribute(k, attrs[k]);
+    }
+    if (text !== undefined) {
+      e.textContent = text;
+    }
+    return e;
+  }
+
+  function key(entry) {
+    return JSON.stringify(entry.Key);
+  }
+
+  function format(v) {
+    if (Math.abs(v) >= 1000) {
+      return v.toFixed(0);
+    }
+    return v.toPrecision(4);
+  }
+
+  var svgNS = "http://www.w3.org/2000/svg";
+
+  function sparkline(series) {
+    var width = 120, height = 20;
+    var svg = document.createElementNS(svgNS, "svg");
+    svg.setAttribute("class", "sparkline");
+    svg.setAttribute("width", width);
+    svg.setAttribute("height", height);
+    var max = Math.max.apply(null, series.concat([0]));
+    var points = series.map(function (v, i) {
+      var x = series.length > 1 ? i * width / (series.length - 1) : width / 2;
+      var y = max > 0 ? height - 1 - v * (height - 2) / max : height - 1;
+      return x.toFixed(1) + "," + y.toFixed(1);
+    });
+    var line = document.createElementNS(svgNS, "polyline");
+    line.setAttribute("points", points.join(" "));
+    svg.appendChild(line);
+    var title = document.createElementNS(svgNS, "title");
+    title.textContent = series.map(format).join(", ");
+    svg.appendChild(title);
+    return svg;
+  }
+
+  // frames returns the stack of an entry, callers first, with files when known.
+  function frames(entry) {
+    if (entry.Key.Frames && entry.Key.Frames.length > 0) {
+      return entry.Key.Frames.map(function (frame) {
+        return frame.Function + (frame.File ? "  " + frame.File : "");
+      });
+    }
+    return entry.Key.Stack ? entry.Key.Stack.split("/") : [entry.Key.Function];
+  }
+
+  function stack(entry) {
+    var ol = el("ol", { "class": "stack" });
+    var f = frames(entry);
+    f.forEach(function (frame, i) {
+      ol.appendChild(el("li", i === f.length - 1 ? { "class": "leaf" } : {}, frame));
+    });
+    return ol;
+  }
+
+  function renderKinds(view) {
+    var nav = document.getElementById("kinds");
+    nav.textContent = "";
+    view.Kinds.forEach(function (k) {
+      var a = el("a", { href: "?kind=" + encodeURIComponent(k) }, k);
+      if (k === view.Kind) {
+        a.className = "current";
+      }
+      a.addEventListener("click", function (ev) {
+        ev.preventDefault();
+        kind = k;
+        history.replaceState(null, "", "?kind=" + encodeURIComponent(k));
+        refresh();
+      });
+      nav.appendChild(a);
+    });
+  }
+
+  function renderEntries(view) {
+    var tbody = document.querySelector("#entries tbody");
+    tbody.textContent = "";
+    var entries = view.Entries || [];
+    document.getElementById("empty").hidden = entries.length > 0;
+    entries.forEach(function (entry, i) {
+      var k = key(entry);
+      var tr = el("tr", { "class": "entry" });
+      tr.appendChild(el("td", { "class": "num" }, String(i + 1)));
+      tr.appendChild(el("td", { "class": "num" }, format(entry.Value)));
+      tr.appendChild(el("td", { "class": "num" }, (100 * (entry.Share || 0)).toFixed(1) + "%"));
+      var trend = el("td");
+      trend.appendChild(sparkline(entry.Series));
+      tr.appendChild(trend);
+      tr.appendChild(el("td", { "class": "function" }, entry.Key.Function));
+      tr.appendChild(el("td", { "class": "file" }, entry.Key.File + (entry.Key.Line ? ":" + entry.Key.Line : "")));
+      tbody.appendChild(tr);
+
+      var details = el("tr", expanded[k] ? {} : { hidden: "" });
+      var td = el("td", { colspan: "6" });
+      td.appendChild(stack(entry));
+      details.appendChild(td);
+      tbody.appendChild(details);
+
+      tr.addEventListener("click", function () {
+        expanded[k] = !expanded[k];
+        details.hidden = !expanded[k];
+      });
+    });
+  }
+
+  function renderTotal(view) {
+    var text = "";
+    if (view.Total) {
+      text = "total " + format(view.Total) + ", not shown " + (100 * view.Dropped / view.Total).toFixed(1) + "%";
+      if (view.Cores) {
+        text += ", " + view.Cores.toFixed(2) + " cores";
+      }
+    }
+    document.getElementById("total").textContent = text;
+  }
+
+  function render(view) {
+    renderKinds(view);
+    renderEntries(view);
+    renderTotal(view);
+    var n = view.Timestamps ? view.Timestamps.length : 0;
+    document.getElementById("status").textContent = n > 0 ?
+      "last update " + new Date(view.Timestamps[n - 1]).toLocaleString() + ", trends over " + n + " profiles" : "";
+  }
+
+  function refresh() {
+    clearTimeout(timer);
+    fetch("data?kind=" + encodeURIComponent(kind), { cache: "no-store" })
+      .then(function (resp) {
+        if (!resp.ok) {
+          throw new Error(resp.statusText);
+        }
+        return resp.json();
+      })
+      .then(function (view) {
+        kind = view.Kind;
+        render(view);
+        timer = setTimeout(refresh, view.Refresh * 1000);
+      })
+      .catch(function (err) {
+        document.getElementById("status").textContent = "error: " + err.message;
+        timer = setTimeout(refresh, 10000);
+      });
+  }
+
+  refresh();
+})();
@@ -0,0 +1,194 @@
+// Live pprof is a Golang library to generate and use live profiles.
+// Copyright (C)  2018  Christian Mauduit <ufoot@ufoot.org>
+// Live pprof homepage: https://github.com/ufoot/livepprof
+// Contact author: ufoot@ufoot.org
+
+// Package dashboard is a web page showing live profiles. It only uses
+// assets embedded in the binary, so it works without Internet access.
+package dashboard
+
+import (
+	"embed"
+	"encoding/json"
+	"io/fs"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ufoot/livepprof"
+	"github.com/ufoot/livepprof/objfile"
+)
+
+//go:embed assets
+var assets embed.FS
+
+// ring is a ring buffer of the last Data of a kind.
+type ring struct {
+	data []livepprof.Data
+	next int
+	full bool
+}
+
+func newRing(size int) *ring {
+	return &ring{data: make([]livepprof.Data, size)}
+}
+
+func (r *ring) push(data livepprof.Data) {
+	r.data[r.next] = data
+	r.next++
+	if r.next == len(r.data) {
+		r.next = 0
+		r.full = true
+	}
+}
+
+// all returns the data, oldest first.
+func (r *ring) all() []livepprof.Data {
+	if !r.full {
+		return append([]livepprof.Data(nil), r.data[:r.next]...)
+	}
+	return append(append([]livepprof.Data(nil), r.data[r.next:]...), r.data[:r.next]...)
+}
+
+// Entry shown on the dashboard.
+type Entry struct {
+	livepprof.Entry
+	// Series is the value of the entry in each Data kept, oldest first,
+	// 0 when the entry is not in it.
+	Series []float64
+}
+
+// View of a kind, as served to the page.
+type View struct {
+	// Kinds there is data for, in alphabetical order.
+	Kinds []string
+	// Kind of this view.
+	Kind string
+	// Refresh is how often the page is refreshed, in seconds.
+	Refresh float64
+	// Timestamps of the Data kept, oldest first.
+	Timestamps []time.Time
+	// Entries of the last Data, with their history.
+	Entries []Entry
+	// Total, Dropped and Cores of the last Data, entries
+	// not shown because of the limit are dropped too.
+	Total   float64
+	Dropped float64
+	Cores   float64 `json:",omitempty"`
+}
+
+// Dashboard is a sink which keeps the last Data of each kind, and an
+// http.Handler serving a page showing them, with the top entries, how
+// they evolved, and their stacks. Mount it on a path ending with a
+// slash, for instance:
+//
+//	mux.Handle("/debug/livepprof/", http.StripPrefix("/debug/livepprof", d))
+type Dashboard struct {
+	opts   opts
+	mu     sync.RWMutex
+	rings  map[string]*ring
+	static http.Handler
+}
+
+var _ livepprof.Sink = &Dashboard{}
+var _ http.Handler = &Dashboard{}
+
+// New dashboard sink and handler.
+func New(options ...Option) (*Dashboard, error) {
+	opts := defaultOpts
+	for _, opt := range options {
+		if err := opt(&opts); err != nil {
+			return nil, err
+		}
+	}
+	sub, err := fs.Sub(assets, "assets")
+	if err != nil {
+		return nil, err
+	}
+	return &Dashboard{
+		opts:   opts,
+		rings:  make(map[string]*ring),
+		static: http.FileServer(http.FS(sub)),
+	}, nil
+}
+
+// Write stores data, the oldest data of the same kind is dropped.
+func (d *Dashboard) Write(kind string, data livepprof.Data) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	r, ok := d.rings[kind]
+	if !ok {
+		r = newRing(d.opts.size)
+		d.rings[kind] = r
+	}
+	r.push(data)
+	return nil
+}
+
+// View returns what the page shows for a kind, the first kind if empty.
+func (d *Dashboard) View(kind string) View {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	view := View{
+		Kinds:   make([]string, 0, len(d.rings)),
+		Refresh: d.opts.refresh.Seconds(),
+	}
+	for k := range d.rings {
+		view.Kinds = append(view.Kinds, k)
+	}
+	sort.Strings(view.Kinds)
+	if kind == "" && len(view.Kinds) > 0 {
+		kind = view.Kinds[0]
+	}
+	view.Kind = kind
+
+	r, ok := d.rings[kind]
+	if !ok {
+		return view
+	}
+	history := r.all()
+	view.Timestamps = make([]time.Time, len(history))
+	for i, data := range history {
+		view.Timestamps[i] = data.Timestamp
+	}
+	lastData := history[len(history)-1]
+	view.Total = lastData.Total
+	view.Dropped = lastData.Dropped
+	view.Cores = lastData.Cores
+	last := lastData.Entries
+	if len(last) > d.opts.limit {
+		for _, entry := range last[d.opts.limit:] {
+			view.Dropped += entry.Value
+		}
+		last = last[:d.opts.limit]
+	}
+	index := make(map[objfile.Location]int, len(last))
+	view.Entries = make([]Entry, len(last))
+	for i, entry := range last {
+		index[entry.Key] = i
+		view.Entries[i] = Entry{Entry: entry, Series: make([]float64, len(history))}
+	}
+	for i, data := range history {
+		for _, entry := range data.Entries {
+			if j, ok := index[entry.Key]; ok {
+				view.Entries[j].Series[i] = entry.Value
+			}
+		}
+	}
+	return view
+}
+
+// ServeHTTP serves the page, its assets, and the data, in JSON, on data.
+func (d *Dashboard) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if strings.TrimPrefix(r.URL.Path, "/") == "data" {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-cache")
+		_ = json.NewEncoder(w).Encode(d.View(r.FormValue("kind")))
+		return
+	}
+	d.static.ServeHTTP(w, r)
+}
@@ -0,0 +1,112 @@
+// Live pprof is a Golang library to generate and use live profiles.
+// Copyright (C)  2018  Christian Mauduit <ufoot@ufoot.org>
+// Live pprof homepage: https://github.com/ufoot/livepprof
+// Contact author: ufoot@ufoot.org
+
+package dashboard
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ufoot/livepprof"
+	"github.com/ufoot/livepprof/objfile"
+)
+
+var (
+	locA = objfile.Location{Function: "github.com/me/a.A", File: "a.go", Stack: "main.main/a.A"}
+	locB = objfile.Location{Function: "github.com/me/a.B", File: "a.go", Stack: "main.main/a.B"}
+	locC = objfile.Location{Function: "github.com/me/a.C", File: "a.go", Stack: "main.main/a.C"}
+)
+
+func TestView(t *testing.T) {
+	assert := assert.New(t)
+
+	d, err := New(WithSize(3), WithLimit(2))
+	assert.Nil(err)
+	assert.Equal(View{Kinds: []string{}, Refresh: 10}, d.View(""))
+
+	ts := time.Now()
+	for i := 0; i < 4; i++ {
+		data := livepprof.Data{Timestamp: ts.Add(time.Duration(i) * time.Minute), Entries: []livepprof.Entry{
+			{Key: locA, Value: float64(10 * i)},
+			{Key: locC, Value: 1},
+		}, Total: float64(10*i) + 1}
+		if i == 2 {
+			data.Entries = append(data.Entries, livepprof.Entry{Key: locB, Value: 5})
+		}
+		assert.Nil(d.Write(livepprof.KindCPU, data))
+	}
+	assert.Nil(d.Write(livepprof.KindHeap, livepprof.Data{Timestamp: ts}))
+
+	view := d.View("")
+	assert.Equal([]string{livepprof.KindCPU, livepprof.KindHeap}, view.Kinds)
+	assert.Equal(livepprof.KindCPU, view.Kind)
+	// The oldest data has been dropped.
+	assert.Equal([]time.Time{ts.Add(time.Minute), ts.Add(2 * time.Minute), ts.Add(3 * time.Minute)}, view.Timestamps)
+	assert.Equal([]Entry{
+		{Entry: livepprof.Entry{Key: locA, Value: 30}, Series: []float64{10, 20, 30}},
+		{Entry: livepprof.Entry{Key: locC, Value: 1}, Series: []float64{1, 1, 1}},
+	}, view.Entries)
+	assert.Equal(31.0, view.Total)
+	assert.Equal(0.0, view.Dropped)
+
+	view = d.View(livepprof.KindHeap)
+	assert.Equal(livepprof.KindHeap, view.Kind)
+	assert.Len(view.Entries, 0)
+
+	_, err = New(WithSize(0))
+	assert.NotNil(err)
+	_, err = New(WithRefresh(time.Millisecond))
+	assert.NotNil(err)
+}
+
+func TestServeHTTP(t *testing.T) {
+	assert := assert.New(t)
+
+	d, err := New()
+	assert.Nil(err)
+	assert.Nil(d.Write(livepprof.KindCPU, livepprof.Data{Timestamp: time.Now(), Entries: []livepprof.Entry{{Key: locA, Value: 1}}}))
+
+	mux := http.NewServeMux()
+	mux.Handle("/debug/livepprof/", http.StripPrefix("/debug/livepprof", d))
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	get := func(path string) (string, string) {
+		resp, err := http.Get(server.URL + path)
+		if !assert.Nil(err) {
+			return "", ""
+		}
+		defer resp.Body.Close()
+		assert.Equal(http.StatusOK, resp.StatusCode, path)
+		body, err := ioutil.ReadAll(resp.Body)
+		assert.Nil(err)
+		return resp.Header.Get("Content-Type"), string(body)
+	}
+
+	contentType, body := get("/debug/livepprof/")
+	assert.True(strings.HasPrefix(contentType, "text/html"))
+	assert.Contains(body, `<script src="dashboard.js"></script>`)
+	// No external assets.
+	assert.NotRegexp(`(src|href)="(https?:)?//`, body)
+	contentType, body = get("/debug/livepprof/dashboard.js")
+	assert.Contains(contentType, "javascript")
+	assert.Contains(body, `fetch("data?kind="`)
+	contentType, _ = get("/debug/livepprof/dashboard.css")
+	assert.True(strings.HasPrefix(contentType, "text/css"))
+
+	contentType, body = get("/debug/livepprof/data?kind=cpu")
+	assert.Equal("application/json", contentType)
+	var view View
+	assert.Nil(json.Unmarshal([]byte(body), &view))
+	assert.Equal(livepprof.KindCPU, view.Kind)
+	assert.Equal([]Entry{{Entry: livepprof.Entry{Key: locA, Value: 1}, Series: []float64{1}}}, view.Entries)
+}
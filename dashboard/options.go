@@ -0,0 +1,70 @@
+// Live pprof is a Golang library to generate and use live profiles.
+// Copyright (C)  2018  Christian Mauduit <ufoot@ufoot.org>
+// Live pprof homepage: https://github.com/ufoot/livepprof
+// Contact author: ufoot@ufoot.org
+
+package dashboard
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	// defaultSize of the ring buffer, the number of Data kept for each kind.
+	defaultSize = 60
+	// defaultLimit of the number of entries shown for each kind.
+	defaultLimit = 20
+	// defaultRefresh of the page.
+	defaultRefresh = 10 * time.Second
+)
+
+type opts struct {
+	size    int
+	limit   int
+	refresh time.Duration
+}
+
+var defaultOpts = opts{
+	size:    defaultSize,
+	limit:   defaultLimit,
+	refresh: defaultRefresh,
+}
+
+// Option passed when creating the dashboard.
+type Option func(o *opts) error
+
+// WithSize allows a custom number of Data to be kept for each kind. Default
+// is 60, which is an hour with the default profiler delay. Sparklines show
+// the values of an entry over all of them.
+func WithSize(size int) Option {
+	return func(o *opts) error {
+		if size <= 0 {
+			return fmt.Errorf("invalid size: %d", size)
+		}
+		o.size = size
+		return nil
+	}
+}
+
+// WithLimit allows a custom number of entries to be shown. Default is 20.
+func WithLimit(limit int) Option {
+	return func(o *opts) error {
+		if limit <= 0 {
+			return fmt.Errorf("invalid limit: %d", limit)
+		}
+		o.limit = limit
+		return nil
+	}
+}
+
+// WithRefresh allows a custom page refresh delay to be used. Default is 10 seconds.
+func WithRefresh(refresh time.Duration) Option {
+	return func(o *opts) error {
+		if refresh < time.Second {
+			return fmt.Errorf("invalid refresh: %s", refresh.String())
+		}
+		o.refresh = refresh
+		return nil
+	}
+}
@@ -19,6 +19,11 @@ type Entry struct {
 	Key objfile.Location
 	// Value is the measured value (bytes, CPU cycles...)
 	Value float64
+	// Share of the total, between 0 and 1, see Data.Total.
+	Share float64
+	// Cores is the value as a number of cores used, 1 for a core
+	// used all the time, only for CPU data.
+	Cores float64 `json:",omitempty"`
 }
 
 // Data passed in channels.
@@ -27,8 +32,22 @@ type Data struct {
 	Timestamp time.Time
 	// Entries, sorted by order of importance, greater numbers at the beginning.
 	Entries []Entry
+	// Total of the values of all entries, including the dropped ones.
+	Total float64
+	// Dropped is the total of the values of the entries which are not
+	// in Entries, because of the limit, see WithLimit.
+	Dropped float64
+	// Cores is the total as a number of cores used, only for CPU data.
+	Cores float64 `json:",omitempty"`
+	// SampleType is the pprof sample type values come from, such as
+	// samples for CPU, or heap.SampleTypeInuseSpace, see ValueType.
+	SampleType string `json:",omitempty"`
 }
 
+// cpuHz is the rate at which runtime/pprof samples the CPU,
+// CPU values are in samples per second.
+const cpuHz = 100
+
 type sortEntries struct {
 	entries []Entry
 }
@@ -51,8 +70,12 @@ func (se *sortEntries) Less(i, j int) bool {
 	}
 
 	// unable to sort on value, sorting by key (should be rare)
-	keyI := se.entries[i].Key
-	keyJ := se.entries[j].Key
+	return LessLocation(se.entries[i].Key, se.entries[j].Key)
+}
+
+// LessLocation is an arbitrary but stable order on locations, used to sort
+// entries which have the same value.
+func LessLocation(keyI, keyJ objfile.Location) bool {
 	if cmp := strings.Compare(keyI.Function, keyJ.Function); cmp < 0 {
 		return true
 	} else if cmp > 0 {
@@ -65,10 +88,28 @@ func (se *sortEntries) Less(i, j int) bool {
 	}
 	if cmp := strings.Compare(keyI.Stack, keyJ.Stack); cmp < 0 {
 		return true
+	} else if cmp > 0 {
+		return false
 	}
-	return false
+	if keyI.Line != keyJ.Line {
+		return keyI.Line < keyJ.Line
+	}
+	return keyI.Frames < keyJ.Frames
+}
+
+// NewData builds data from values by location, as collectors return them,
+// with locations already aggregated with their key. Entries are sorted,
+// greater values first, and only the first limit ones are kept, the total
+// and what is dropped are computed before. Cores are not set, as the kind of
+// data is not known. The timestamp is truncated to the millisecond. This
+// is what the profiler does on each collection, it can be used on profiles
+// of other programs, see collector.Aggregate.
+func NewData(ts time.Time, rawData map[objfile.Location]float64, limit int) Data {
+	return buildData(ts, rawData, limit)
 }
 
+// buildData sorts and limits data. Collectors have already aggregated
+// locations with the key, see collector.WithKey.
 func buildData(ts time.Time, rawData map[objfile.Location]float64, limit int) Data {
 	ts = ts.Truncate(time.Millisecond) // makes logs easier to read
 
@@ -76,13 +117,23 @@ func buildData(ts time.Time, rawData map[objfile.Location]float64, limit int) Da
 		return Data{Timestamp: ts}
 	}
 
+	n := limit
+	if len(rawData) < n {
+		n = len(rawData)
+	}
 	ret := Data{
 		Timestamp: ts,
-		Entries:   make([]Entry, 0, limit),
+		Entries:   make([]Entry, 0, n),
 	}
 
 	for k, v := range rawData {
 		ret.Entries = append(ret.Entries, Entry{Key: k, Value: v})
+		ret.Total += v
+	}
+	if ret.Total != 0 {
+		for i := range ret.Entries {
+			ret.Entries[i].Share = ret.Entries[i].Value / ret.Total
+		}
 	}
 
 	se := sortEntries{entries: ret.Entries}
@@ -90,8 +141,32 @@ func buildData(ts time.Time, rawData map[objfile.Location]float64, limit int) Da
 	ret.Entries = se.entries
 
 	if len(ret.Entries) > limit {
+		for _, entry := range ret.Entries[limit:] {
+			ret.Dropped += entry.Value
+		}
 		ret.Entries = ret.Entries[:limit]
 	}
 
 	return ret
 }
+
+// normalizeCPU sets the number of cores used, for CPU data.
+func (d *Data) normalizeCPU() {
+	d.Cores = d.Total / cpuHz
+	for i := range d.Entries {
+		d.Entries[i].Cores = d.Entries[i].Value / cpuHz
+	}
+}
+
+// buildData builds data of a kind, as it is sent to channels and sinks.
+func (lp *LP) buildData(kind string, ts time.Time, rawData map[objfile.Location]float64) Data {
+	data := buildData(ts, rawData, lp.opts.limit)
+	data.SampleType = defaultSampleType(kind)
+	if kind == KindHeap && lp.opts.heapSample != "" {
+		data.SampleType = lp.opts.heapSample
+	}
+	if kind == KindCPU {
+		data.normalizeCPU()
+	}
+	return data
+}
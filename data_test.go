@@ -0,0 +1,66 @@
+// Live pprof is a Golang library to generate and use live profiles.
+// Copyright (C)  2018  Christian Mauduit <ufoot@ufoot.org>
+// Live pprof homepage: https://github.com/ufoot/livepprof
+// Contact author: ufoot@ufoot.org
+
+package livepprof
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ufoot/livepprof/collector/heap"
+	"github.com/ufoot/livepprof/objfile"
+)
+
+// keyed aggregates raw data with a key, as collectors do.
+func keyed(rawData map[objfile.Location]float64, key objfile.Key) map[objfile.Location]float64 {
+	ret := make(map[objfile.Location]float64, len(rawData))
+	for k, v := range rawData {
+		ret[key.Apply(k)] += v
+	}
+	return ret
+}
+
+func TestBuildData(t *testing.T) {
+	assert := assert.New(t)
+
+	rawData := map[objfile.Location]float64{
+		{Function: "github.com/me/a.F", File: "a.go", Stack: "main.main/a.F", Line: 1}: 1,
+		{Function: "github.com/me/a.F", File: "a.go", Stack: "main.main/a.F", Line: 2}: 2,
+		{Function: "github.com/me/a.G", File: "a.go", Stack: "main.main/a.G", Line: 3}: 6,
+		{Function: "github.com/me/b.H", File: "b.go", Stack: "main.main/b.H", Line: 4}: 8,
+	}
+	ts := time.Now()
+
+	data := buildData(ts, keyed(rawData, objfile.Key{}), 10)
+	assert.Equal(ts.Truncate(time.Millisecond), data.Timestamp)
+	assert.Equal(3, len(data.Entries))
+	assert.Equal(Entry{Key: objfile.Location{Function: "github.com/me/b.H", File: "b.go", Stack: "main.main/b.H"}, Value: 8, Share: 8.0 / 17}, data.Entries[0])
+	assert.Equal(3.0, data.Entries[2].Value)
+	assert.Equal(17.0, data.Total)
+	assert.Equal(0.0, data.Dropped)
+	assert.Equal(0.0, data.Cores)
+
+	data = buildData(ts, keyed(rawData, objfile.Key{Granularity: objfile.GranularityLine}), 10)
+	assert.Equal(4, len(data.Entries))
+	assert.Equal(1, data.Entries[3].Key.Line)
+
+	data = buildData(ts, keyed(rawData, objfile.Key{Granularity: objfile.GranularityPackage}), 1)
+	assert.Equal([]Entry{{Key: objfile.Location{Function: "github.com/me/a"}, Value: 9, Share: 9.0 / 17}}, data.Entries)
+	assert.Equal(17.0, data.Total)
+	assert.Equal(8.0, data.Dropped)
+
+	// CPU values are samples per second, at 100 Hz.
+	lp := &LP{opts: defaultOpts}
+	lp.opts.limit = 1
+	data = lp.buildData(KindCPU, ts, rawData)
+	assert.Equal(0.17, data.Cores)
+	assert.Equal(0.08, data.Entries[0].Cores)
+	assert.Equal("samples", data.SampleType)
+	assert.Equal(0.0, lp.buildData(KindHeap, ts, rawData).Cores)
+	lp.opts.heapSample = heap.SampleTypeAllocSpace
+	assert.Equal("alloc_space", lp.buildData(KindHeap, ts, rawData).SampleType)
+}
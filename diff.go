@@ -0,0 +1,141 @@
+// Live pprof is a Golang library to generate and use live profiles.
+// Copyright (C)  2018  Christian Mauduit <ufoot@ufoot.org>
+// Live pprof homepage: https://github.com/ufoot/livepprof
+// Contact author: ufoot@ufoot.org
+
+package livepprof
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/ufoot/livepprof/objfile"
+)
+
+// ChangeKind tells how an entry changed between two Data.
+type ChangeKind string
+
+const (
+	// ChangeUpdated is for entries in both Data.
+	ChangeUpdated ChangeKind = "updated"
+	// ChangeNew is for entries only in the current Data.
+	ChangeNew ChangeKind = "new"
+	// ChangeGone is for entries only in the previous Data.
+	ChangeGone ChangeKind = "gone"
+)
+
+// Change of one entry between two Data.
+type Change struct {
+	// Key is the aggregation key for data, basically a location in the code.
+	Key objfile.Location
+	// Kind tells whether the entry is new, gone, or in both Data.
+	Kind ChangeKind
+	// Prev is the value in the previous Data, 0 for new entries.
+	Prev float64
+	// Cur is the value in the current Data, 0 for gone entries.
+	Cur float64
+	// Delta is Cur-Prev.
+	Delta float64
+	// Relative is Delta/Prev, so 1 means the value doubled, and -1 that
+	// it is gone. It is 0 for new entries, there's nothing to compare to.
+	Relative float64
+}
+
+// DataDiff is the difference between two Data.
+type DataDiff struct {
+	// Prev is the timestamp of the previous Data.
+	Prev time.Time
+	// Cur is the timestamp of the current Data.
+	Cur time.Time
+	// Changes, sorted by magnitude, greater absolute deltas at the beginning.
+	// Entries with the same value in both Data are not listed.
+	Changes []Change
+}
+
+type sortChanges struct {
+	changes []Change
+}
+
+func (sc *sortChanges) Len() int {
+	return len(sc.changes)
+}
+
+func (sc *sortChanges) Swap(i, j int) {
+	sc.changes[i], sc.changes[j] = sc.changes[j], sc.changes[i]
+}
+
+func (sc *sortChanges) Less(i, j int) bool {
+	deltaI := math.Abs(sc.changes[i].Delta)
+	deltaJ := math.Abs(sc.changes[j].Delta)
+	if deltaI > deltaJ {
+		return true
+	}
+	if deltaI < deltaJ {
+		return false
+	}
+	return LessLocation(sc.changes[i].Key, sc.changes[j].Key)
+}
+
+// Diff returns what changed between prev and cur. Both should be
+// aggregated with the same key. Data only contains the first entries,
+// so an entry reported as new or gone may just have crossed the limit,
+// use a greater limit than what is looked at to avoid this.
+func Diff(prev, cur Data) DataDiff {
+	values := make(map[objfile.Location]float64, len(prev.Entries))
+	for _, entry := range prev.Entries {
+		values[entry.Key] += entry.Value
+	}
+
+	ret := DataDiff{Prev: prev.Timestamp, Cur: cur.Timestamp}
+	seen := make(map[objfile.Location]struct{}, len(cur.Entries))
+	for _, entry := range cur.Entries {
+		seen[entry.Key] = struct{}{}
+		v, ok := values[entry.Key]
+		if !ok {
+			ret.Changes = append(ret.Changes, Change{
+				Key:   entry.Key,
+				Kind:  ChangeNew,
+				Cur:   entry.Value,
+				Delta: entry.Value,
+			})
+			continue
+		}
+		if v == entry.Value {
+			continue
+		}
+		c := Change{
+			Key:   entry.Key,
+			Kind:  ChangeUpdated,
+			Prev:  v,
+			Cur:   entry.Value,
+			Delta: entry.Value - v,
+		}
+		if v != 0 {
+			c.Relative = c.Delta / v
+		}
+		ret.Changes = append(ret.Changes, c)
+	}
+	for _, entry := range prev.Entries {
+		if _, ok := seen[entry.Key]; ok {
+			continue
+		}
+		seen[entry.Key] = struct{}{}
+		c := Change{
+			Key:   entry.Key,
+			Kind:  ChangeGone,
+			Prev:  entry.Value,
+			Delta: -entry.Value,
+		}
+		if entry.Value != 0 {
+			c.Relative = -1
+		}
+		ret.Changes = append(ret.Changes, c)
+	}
+
+	sc := sortChanges{changes: ret.Changes}
+	sort.Sort(&sc)
+	ret.Changes = sc.changes
+
+	return ret
+}
@@ -0,0 +1,41 @@
+// Live pprof is a Golang library to generate and use live profiles.
+// Copyright (C)  2018  Christian Mauduit <ufoot@ufoot.org>
+// Live pprof homepage: https://github.com/ufoot/livepprof
+// Contact author: ufoot@ufoot.org
+
+package livepprof
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ufoot/livepprof/objfile"
+)
+
+func TestDiff(t *testing.T) {
+	assert := assert.New(t)
+
+	a := objfile.Location{Function: "github.com/me/a.A", File: "a.go", Stack: "main.main/a.A"}
+	b := objfile.Location{Function: "github.com/me/a.B", File: "a.go", Stack: "main.main/a.B"}
+	c := objfile.Location{Function: "github.com/me/a.C", File: "a.go", Stack: "main.main/a.C"}
+	d := objfile.Location{Function: "github.com/me/a.D", File: "a.go", Stack: "main.main/a.D"}
+	e := objfile.Location{Function: "github.com/me/a.E", File: "a.go", Stack: "main.main/a.E"}
+	ts := time.Now()
+
+	prev := Data{Timestamp: ts, Entries: []Entry{{Key: a, Value: 8}, {Key: b, Value: 4}, {Key: c, Value: 2}, {Key: e, Value: 1}}}
+	cur := Data{Timestamp: ts.Add(time.Minute), Entries: []Entry{{Key: b, Value: 10}, {Key: d, Value: 3}, {Key: a, Value: 2}, {Key: e, Value: 1}}}
+
+	diff := Diff(prev, cur)
+	assert.Equal(ts, diff.Prev)
+	assert.Equal(ts.Add(time.Minute), diff.Cur)
+	assert.Equal([]Change{
+		{Key: a, Kind: ChangeUpdated, Prev: 8, Cur: 2, Delta: -6, Relative: -0.75},
+		{Key: b, Kind: ChangeUpdated, Prev: 4, Cur: 10, Delta: 6, Relative: 1.5},
+		{Key: d, Kind: ChangeNew, Cur: 3, Delta: 3},
+		{Key: c, Kind: ChangeGone, Prev: 2, Delta: -2, Relative: -1},
+	}, diff.Changes)
+
+	assert.Nil(Diff(cur, cur).Changes)
+}
@@ -0,0 +1,213 @@
+// Live pprof is a Golang library to generate and use live profiles.
+// Copyright (C)  2018  Christian Mauduit <ufoot@ufoot.org>
+// Live pprof homepage: https://github.com/ufoot/livepprof
+// Contact author: ufoot@ufoot.org
+
+package livepprof
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ufoot/livepprof/objfile"
+)
+
+const (
+	// flameWidth of flame graphs, in pixels.
+	flameWidth = 1200
+	// flameFrameHeight is the height of a frame, in pixels.
+	flameFrameHeight = 16
+	// flameTop is the space for the title, in pixels.
+	flameTop = 40
+	// flameMargin on each side, in pixels.
+	flameMargin = 10
+	// flameFontSize in pixels, a character is about 0.6 times this wide.
+	flameFontSize = 12
+	// flameMinWidth under which frames are not drawn, in pixels.
+	flameMinWidth = 0.1
+)
+
+// frames returns the functions of the stack of an entry, callers first.
+func frames(key objfile.Location) []string {
+	if key.Stack == "" {
+		return []string{key.Function}
+	}
+	return objfile.SplitStack(key.Stack)
+}
+
+// foldedStacks returns values by folded stack, averaged if there are several data.
+func foldedStacks(data []Data) map[string]float64 {
+	ret := make(map[string]float64)
+	for _, d := range data {
+		for _, entry := range d.Entries {
+			ret[strings.Join(frames(entry.Key), ";")] += entry.Value
+		}
+	}
+	for k := range ret {
+		ret[k] /= float64(len(data))
+	}
+	return ret
+}
+
+// WriteFolded writes data in the folded stack format, also known as the
+// collapsed format, one line per stack, callers first, separated by
+// semicolons, then a space and the value. This is what flame graph tools
+// such as flamegraph.pl or speedscope read. If several data are given,
+// typically a time window, values are averaged. Values are not rounded,
+// as most of them, CPU in particular, are small numbers per second.
+func WriteFolded(w io.Writer, data ...Data) error {
+	stacks := foldedStacks(data)
+	keys := make([]string, 0, len(stacks))
+	for k := range stacks {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	bw := bufio.NewWriter(w)
+	for _, k := range keys {
+		if _, err := bw.WriteString(k + " " + strconv.FormatFloat(stacks[k], 'f', -1, 64) + "\n"); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// flameNode is a frame of a flame graph, with the total value of its stacks.
+type flameNode struct {
+	name     string
+	value    float64
+	children map[string]*flameNode
+}
+
+func (n *flameNode) add(frames []string, value float64) {
+	n.value += value
+	if len(frames) == 0 {
+		return
+	}
+	if n.children == nil {
+		n.children = make(map[string]*flameNode)
+	}
+	child, ok := n.children[frames[0]]
+	if !ok {
+		child = &flameNode{name: frames[0]}
+		n.children[frames[0]] = child
+	}
+	child.add(frames[1:], value)
+}
+
+func (n *flameNode) depth() int {
+	d := 0
+	for _, child := range n.children {
+		if cd := child.depth() + 1; cd > d {
+			d = cd
+		}
+	}
+	return d
+}
+
+// sortedChildren returns children in alphabetical order, as flamegraph.pl does.
+func (n *flameNode) sortedChildren() []*flameNode {
+	ret := make([]*flameNode, 0, len(n.children))
+	for _, child := range n.children {
+		ret = append(ret, child)
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].name < ret[j].name })
+	return ret
+}
+
+// flameColor returns a warm color, which only depends on the name, so
+// that the same function has the same color in all graphs.
+func flameColor(name string) string {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(name))
+	v := h.Sum32()
+	r := 205 + v%50
+	g := (v >> 8) % 230
+	b := (v >> 16) % 55
+	return fmt.Sprintf("rgb(%d,%d,%d)", r, g, b)
+}
+
+func escape(s string) string {
+	var sb strings.Builder
+	_ = xml.EscapeText(&sb, []byte(s))
+	return sb.String()
+}
+
+type flameWriter struct {
+	w      *bufio.Writer
+	scale  float64
+	total  float64
+	height int
+}
+
+// truncate shortens text to at most chars runes, ending with .. if it is cut.
+func truncate(text string, chars int) string {
+	if utf8.RuneCountInString(text) <= chars {
+		return text
+	}
+	return string([]rune(text)[:chars-2]) + ".."
+}
+
+// frame writes a node, and its children, recursively.
+func (fw *flameWriter) frame(n *flameNode, x float64, level int) {
+	width := n.value * fw.scale
+	if width < flameMinWidth {
+		return
+	}
+	y := fw.height - flameMargin - (level+1)*flameFrameHeight
+	fmt.Fprintf(fw.w, "<g>\n<title>%s (%s, %0.2f%%)</title>\n", escape(n.name), strconv.FormatFloat(n.value, 'g', 6, 64), 100*n.value/fw.total)
+	fmt.Fprintf(fw.w, `<rect x="%0.1f" y="%d" width="%0.1f" height="%d" fill="%s" rx="2" ry="2"/>`+"\n",
+		x, y, width, flameFrameHeight-1, flameColor(n.name))
+	if chars := int(width / (0.6 * flameFontSize)); chars >= 3 {
+		fmt.Fprintf(fw.w, `<text x="%0.1f" y="%d">%s</text>`+"\n", x+3, y+flameFrameHeight-4, escape(truncate(n.name, chars)))
+	}
+	fw.w.WriteString("</g>\n")
+	for _, child := range n.sortedChildren() {
+		fw.frame(child, x, level+1)
+		x += child.value * fw.scale
+	}
+}
+
+// WriteFlameGraph writes data as a flame graph, in SVG, callers at the
+// bottom, and the functions they call on top of them. Each frame is as wide
+// as its value, the sum of the values of all the stacks it is in. Hovering
+// on a frame shows its name and value. The SVG is self-contained, it can be
+// opened with a browser, there's no need for pprof nor for anything else.
+// If several data are given, typically a time window, values are averaged.
+func WriteFlameGraph(w io.Writer, title string, data ...Data) error {
+	root := &flameNode{name: "all"}
+	for stack, value := range foldedStacks(data) {
+		if value <= 0 {
+			continue
+		}
+		root.add(strings.Split(stack, ";"), value)
+	}
+
+	fw := flameWriter{
+		w:      bufio.NewWriter(w),
+		total:  root.value,
+		height: flameTop + (root.depth()+1)*flameFrameHeight + 2*flameMargin,
+	}
+	if root.value > 0 {
+		fw.scale = (flameWidth - 2*flameMargin) / root.value
+	}
+
+	fmt.Fprintf(fw.w, `<?xml version="1.0" standalone="no"?>`+"\n")
+	fmt.Fprintf(fw.w, `<svg version="1.1" width="%d" height="%d" viewBox="0 0 %d %d" xmlns="http://www.w3.org/2000/svg">`+"\n",
+		flameWidth, fw.height, flameWidth, fw.height)
+	fmt.Fprintf(fw.w, "<style>text { font-family: Verdana, sans-serif; font-size: %dpx; fill: #000; pointer-events: none; } rect:hover { stroke: #000; stroke-width: 0.5; }</style>\n", flameFontSize)
+	fmt.Fprintf(fw.w, `<rect x="0" y="0" width="%d" height="%d" fill="#eeeeee"/>`+"\n", flameWidth, fw.height)
+	fmt.Fprintf(fw.w, `<text x="%d" y="24" text-anchor="middle" style="font-size: 17px">%s</text>`+"\n", flameWidth/2, escape(title))
+	if root.value > 0 {
+		fw.frame(root, flameMargin, 0)
+	}
+	fw.w.WriteString("</svg>\n")
+	return fw.w.Flush()
+}
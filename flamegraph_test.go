@@ -0,0 +1,89 @@
+// Live pprof is a Golang library to generate and use live profiles.
+// Copyright (C)  2018  Christian Mauduit <ufoot@ufoot.org>
+// Live pprof homepage: https://github.com/ufoot/livepprof
+// Contact author: ufoot@ufoot.org
+
+package livepprof
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ufoot/livepprof/objfile"
+)
+
+func flameData() []Data {
+	ts := time.Now()
+	return []Data{
+		{Timestamp: ts, Entries: []Entry{
+			{Key: objfile.Location{Function: "github.com/me/a.A", File: "a.go", Stack: "main.main/a.A"}, Value: 3},
+			{Key: objfile.Location{Function: "github.com/me/a.B", File: "a.go", Stack: "main.main/a.A/a.B"}, Value: 2},
+			{Key: objfile.Location{Function: "github.com/me/a.C", File: "a.go", Stack: "main.main/a.C"}, Value: 1},
+		}},
+		{Timestamp: ts.Add(time.Minute), Entries: []Entry{
+			{Key: objfile.Location{Function: "github.com/me/a.A", File: "a.go", Stack: "main.main/a.A"}, Value: 5},
+			{Key: objfile.Location{Function: "github.com/me/a.B", File: "a.go", Stack: "main.main/a.A/a.B"}, Value: 2},
+			{Key: objfile.Location{Function: "github.com/me/b.D<&>"}, Value: 0.5},
+		}},
+	}
+}
+
+func TestWriteFolded(t *testing.T) {
+	assert := assert.New(t)
+
+	var buf bytes.Buffer
+	assert.Nil(WriteFolded(&buf, flameData()...))
+	assert.Equal(`github.com/me/b.D<&> 0.25
+main.main;a.A 4
+main.main;a.A;a.B 2
+main.main;a.C 0.5
+`, buf.String())
+}
+
+func TestWriteFlameGraph(t *testing.T) {
+	assert := assert.New(t)
+
+	var buf bytes.Buffer
+	assert.Nil(WriteFlameGraph(&buf, "cpu <test>", flameData()...))
+	svg := buf.String()
+
+	// Well formed XML.
+	dec := xml.NewDecoder(strings.NewReader(svg))
+	for {
+		_, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if !assert.Nil(err) {
+			break
+		}
+	}
+	assert.Contains(svg, "<title>all (6.75, 100.00%)</title>")
+	assert.Contains(svg, "<title>main.main (6.5, 96.30%)</title>")
+	assert.Contains(svg, "<title>a.B (2, 29.63%)</title>")
+	assert.Contains(svg, "<title>github.com/me/b.D&lt;&amp;&gt; (0.25, 3.70%)</title>")
+	assert.Contains(svg, "cpu &lt;test&gt;")
+	// Callers at the bottom, one row per level: all, main.main, a.A, a.B.
+	assert.Contains(svg, `height="124"`)
+
+	buf.Reset()
+	assert.Nil(WriteFlameGraph(&buf, "empty"))
+	assert.NotContains(buf.String(), "<rect x=\"10")
+}
+
+func TestTruncate(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("main.main", truncate("main.main", 9))
+	assert.Equal("main...", truncate("main.main", 7))
+	// Multi-byte characters are not split.
+	assert.Equal("ééé..", truncate("éééééé", 5))
+	assert.True(utf8.ValidString(truncate("日本語のパッケージ.F", 6)))
+}
@@ -0,0 +1,15 @@
+module github.com/ufoot/livepprof
+
+go 1.23
+
+require (
+	github.com/google/pprof v0.0.0-20250403155104-27863c87afa6
+	github.com/ianlancetaylor/demangle v0.0.0-20240312041847-bd984b5ce465
+	github.com/stretchr/testify v1.10.0
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
@@ -6,29 +6,75 @@
 package livepprof
 
 import (
+	"context"
 	"math/rand"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/google/pprof/profile"
+
 	"github.com/ufoot/livepprof/collector"
+	"github.com/ufoot/livepprof/collector/block"
 	"github.com/ufoot/livepprof/collector/cpu"
+	"github.com/ufoot/livepprof/collector/goroutine"
 	"github.com/ufoot/livepprof/collector/heap"
+	"github.com/ufoot/livepprof/collector/mutex"
+	"github.com/ufoot/livepprof/objfile"
+)
+
+const (
+	// KindCPU identifies CPU data.
+	KindCPU = "cpu"
+	// KindHeap identifies heap data.
+	KindHeap = "heap"
+	// KindGoroutine identifies goroutine data.
+	KindGoroutine = "goroutine"
+	// KindMutex identifies mutex contention data.
+	KindMutex = "mutex"
+	// KindBlock identifies blocking data.
+	KindBlock = "block"
 )
 
+// stream is a collector, and the channel its data is sent on.
+type stream struct {
+	kind      string
+	collector collector.ContextCollector
+	out       chan Data
+	// raw is the last collected profile, only kept if it is archived.
+	raw *profile.Profile
+	// read is set, atomically, once the channel has been asked for.
+	// Until then, nobody is reading it, data is only sent to sinks.
+	read int32
+}
+
+// disabled tells wether the stream has been disabled, by options.
+// Its channel is closed from the start, so that reading it does not block.
+func (s *stream) disabled() bool {
+	return s.collector == nil
+}
+
 // LP is an implementation of a live profiler.
 type LP struct {
-	opts          opts
-	cpuCollector  collector.Collector
-	heapCollector collector.Collector
-	cpus          chan Data
-	heaps         chan Data
+	opts    opts
+	streams []*stream
 	// rand is a local random number generator. There's a reason
 	// to not use the global rand, which is that doing so, we would
 	// alter any user code that relies on it for predictable numbers.
 	rand *rand.Rand
-	exit chan struct{}
-	wg   sync.WaitGroup
-	mu   sync.RWMutex
+	// ctx is cancelled when stopping, it's nil when stopped.
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	mu     sync.RWMutex
+	// sinks have their own lock, as they live until LP is closed,
+	// whether it is started or stopped.
+	sinks  []*sinkQueue
+	sinkWg sync.WaitGroup
+	sinkMu sync.RWMutex
+	// heapSnapshot is not shared with the background loop, so that
+	// cumulative heap data is computed between snapshots.
+	heapSnapshot collector.ContextCollector
 }
 
 // Profiler is a generic profiler interface.
@@ -45,36 +91,138 @@ func New(options ...Option) (*LP, error) {
 			return nil, err
 		}
 	}
+	if opts.cacheCapacity > 0 && opts.backend != objfile.BackendProfile {
+		of, err := objfile.Open(opts.backend)
+		if err != nil {
+			return nil, err
+		}
+		if err := of.SetCacheCapacity(opts.cacheCapacity); err != nil {
+			return nil, err
+		}
+	}
+
 	lp := &LP{
-		opts:          opts,
-		cpuCollector:  cpu.New(opts.filter, opts.delay),
-		heapCollector: heap.New(opts.filter),
-		cpus:          make(chan Data),
-		heaps:         make(chan Data),
+		opts: opts,
 		// seed our local rand source with local time, it's OK, we
 		// don't need cryptographic random here, just a local skew
 		// so that everything does not heartbeat at the same pace.
 		rand: rand.New(rand.NewSource(time.Now().UnixNano())),
 	}
+	lp.addStream(KindCPU, func(options ...collector.Option) collector.ContextCollector {
+		return cpu.New(opts.filter, opts.delay, append(options, collector.WithBusyPolicy(opts.busyPolicy))...)
+	})
+	lp.addStream(KindHeap, func(options ...collector.Option) collector.ContextCollector {
+		return heap.New(opts.filter, append(options, collector.WithSampleType(opts.heapSample))...)
+	})
+	lp.addStream(KindGoroutine, func(options ...collector.Option) collector.ContextCollector {
+		return goroutine.New(opts.filter, options...)
+	})
+	if opts.mutexFraction > 0 {
+		lp.addStream(KindMutex, func(options ...collector.Option) collector.ContextCollector {
+			return mutex.New(opts.filter, opts.delay, opts.mutexFraction, options...)
+		})
+	} else {
+		lp.addDisabledStream(KindMutex)
+	}
+	if opts.blockRate != 0 {
+		lp.addStream(KindBlock, func(options ...collector.Option) collector.ContextCollector {
+			// A negative rate enables the stream, but the rate is left as is.
+			return block.New(opts.filter, opts.delay, opts.blockRate, options...)
+		})
+	} else {
+		lp.addDisabledStream(KindBlock)
+	}
+	lp.heapSnapshot = heap.New(opts.filter, append(lp.collectorOptions(), collector.WithSampleType(opts.heapSample))...)
 
+	lp.startSinks()
 	lp.Start()
 	return lp, nil
 }
 
-// CPU channel on which cpu data is sent.
-func (lp *LP) CPU() <-chan Data {
+// collectorOptions returns the options common to all collectors.
+func (lp *LP) collectorOptions() []collector.Option {
+	options := []collector.Option{collector.WithBackend(lp.opts.backend), collector.WithKey(lp.opts.key)}
+	if lp.opts.leafFilter != nil {
+		options = append(options, collector.WithFilter(lp.opts.leafFilter))
+	}
+	return options
+}
+
+// addStream creates a collector with the options common to all kinds,
+// and the channel its data is sent on.
+func (lp *LP) addStream(kind string, newCollector func(options ...collector.Option) collector.ContextCollector) {
+	s := &stream{kind: kind, out: make(chan Data)}
+	options := lp.collectorOptions()
+	if lp.archives(kind) {
+		// Called from Collect, so in the same goroutine as run, no need to lock.
+		options = append(options, collector.WithRawHandler(func(gp *profile.Profile) {
+			s.raw = gp
+		}))
+	}
+	s.collector = newCollector(options...)
+	lp.streams = append(lp.streams, s)
+}
+
+// addDisabledStream adds a stream which never sends anything.
+func (lp *LP) addDisabledStream(kind string) {
+	s := &stream{kind: kind, out: make(chan Data)}
+	close(s.out)
+	lp.streams = append(lp.streams, s)
+}
+
+// CacheStats returns counters about the cache of resolved locations.
+// The cache is shared by all profilers using the same backend, and
+// there's none with objfile.BackendProfile.
+func (lp *LP) CacheStats() objfile.CacheStats {
+	if lp.opts.backend == objfile.BackendProfile {
+		return objfile.CacheStats{}
+	}
+	of, err := objfile.Open(lp.opts.backend)
+	if err != nil {
+		return objfile.CacheStats{}
+	}
+	return of.CacheStats()
+}
+
+// channel returns the channel for a given kind of data, nil if closed.
+func (lp *LP) channel(kind string) <-chan Data {
 	lp.mu.RLock()
 	defer lp.mu.RUnlock()
 
-	return lp.cpus
+	for _, s := range lp.streams {
+		if s.kind == kind {
+			atomic.StoreInt32(&s.read, 1)
+			return s.out
+		}
+	}
+	return nil
+}
+
+// CPU channel on which cpu data is sent.
+func (lp *LP) CPU() <-chan Data {
+	return lp.channel(KindCPU)
 }
 
 // Heap channel on which heap data is sent.
 func (lp *LP) Heap() <-chan Data {
-	lp.mu.RLock()
-	defer lp.mu.RUnlock()
+	return lp.channel(KindHeap)
+}
+
+// Goroutine channel on which goroutine data is sent.
+func (lp *LP) Goroutine() <-chan Data {
+	return lp.channel(KindGoroutine)
+}
+
+// Mutex channel on which mutex contention data is sent. It is closed
+// unless mutex profiles are enabled, see WithMutexFraction.
+func (lp *LP) Mutex() <-chan Data {
+	return lp.channel(KindMutex)
+}
 
-	return lp.heaps
+// Block channel on which blocking data is sent. It is closed
+// unless blocking profiles are enabled, see WithBlockRate.
+func (lp *LP) Block() <-chan Data {
+	return lp.channel(KindBlock)
 }
 
 func (lp *LP) handleErr(err error) {
@@ -83,51 +231,93 @@ func (lp *LP) handleErr(err error) {
 	}
 }
 
-func (lp *LP) runCPUs(cpus chan<- Data) {
-	defer lp.wg.Done()
+// read tells wether the channel of a stream has been asked for.
+func (s *stream) isRead() bool {
+	return atomic.LoadInt32(&s.read) != 0
+}
 
-	ticker := time.NewTicker(lp.opts.jitteredDelay(lp.rand))
-	defer ticker.Stop()
+// wanted tells wether anything uses the data of a stream,
+// if not, there's no need to collect it.
+func (lp *LP) wanted(s *stream) bool {
+	return s.isRead() || len(lp.opts.sinks) > 0 || lp.archives(s.kind)
+}
 
-	for {
+// archives tells wether the raw profiles of a kind are archived,
+// see KindArchiver.
+func (lp *LP) archives(kind string) bool {
+	if lp.opts.archiver == nil {
+		return false
+	}
+	if ka, ok := lp.opts.archiver.(KindArchiver); ok {
+		return ka.Archives(kind)
+	}
+	return true
+}
+
+// send data to sinks and to the stream channel. If there are sinks, or
+// if the channel has never been asked for, the channel is optional, data
+// is only sent if someone is reading it.
+func (lp *LP) send(ctx context.Context, s *stream, data Data) {
+	lp.publish(s.kind, data)
+
+	if len(lp.opts.sinks) == 0 && s.isRead() {
 		select {
-		case now := <-ticker.C:
-			if !lp.opts.enabled() {
-				continue
-			}
-			rawData, err := lp.cpuCollector.Collect(lp.exit)
-			if err != nil {
-				lp.handleErr(err)
-				continue
-			}
-			data := buildData(now, rawData, lp.opts.limit)
-			cpus <- data
-		case <-lp.exit:
-			return
+		case s.out <- data:
+		case <-ctx.Done():
 		}
+		return
+	}
+	select {
+	case s.out <- data:
+	default: // non-blocking
+	}
+}
+
+// archive the last raw profile of a stream, if any.
+func (lp *LP) archive(s *stream, ts time.Time) {
+	if s.raw == nil || !lp.archives(s.kind) {
+		return
+	}
+	if err := lp.opts.archiver.Archive(s.kind, ts, s.raw); err != nil {
+		lp.handleErr(err)
 	}
+	s.raw = nil
 }
 
-func (lp *LP) runHeaps(heaps chan<- Data) {
+// collect data once, within the collection deadline.
+func (lp *LP) collect(ctx context.Context, s *stream) (map[objfile.Location]float64, error) {
+	ctx, cancel := context.WithTimeout(ctx, lp.opts.collectTimeout())
+	defer cancel()
+
+	return s.collector.CollectContext(ctx)
+}
+
+// run collects data on a regular basis, and sends it.
+func (lp *LP) run(ctx context.Context, s *stream, delay time.Duration) {
 	defer lp.wg.Done()
 
-	ticker := time.NewTicker(lp.opts.jitteredDelay(lp.rand))
+	ticker := time.NewTicker(delay)
 	defer ticker.Stop()
 
 	for {
 		select {
 		case now := <-ticker.C:
-			if !lp.opts.enabled() {
+			if !lp.opts.enabled() || !lp.wanted(s) {
 				continue
 			}
-			rawData, err := lp.heapCollector.Collect(lp.exit)
+			rawData, err := lp.collect(ctx, s)
 			if err != nil {
+				if ctx.Err() != nil {
+					// Stopping, this is not an error.
+					return
+				}
 				lp.handleErr(err)
 				continue
 			}
-			data := buildData(now, rawData, lp.opts.limit)
-			heaps <- data
-		case <-lp.exit:
+			data := lp.buildData(s.kind, now, rawData)
+			lp.archive(s, data.Timestamp)
+			lp.send(ctx, s, data)
+		case <-ctx.Done():
 			return
 		}
 	}
@@ -138,38 +328,40 @@ func (lp *LP) Start() {
 	lp.mu.Lock()
 	defer lp.mu.Unlock()
 
-	if lp.exit != nil ||
-		lp.cpuCollector == nil || lp.heapCollector == nil ||
-		lp.cpus == nil || lp.heaps == nil {
+	if lp.ctx != nil || lp.streams == nil {
 		return
 	}
 
-	lp.exit = make(chan struct{})
+	lp.ctx, lp.cancel = context.WithCancel(lp.opts.ctx)
 
-	lp.wg.Add(2)
-	go lp.runCPUs(lp.cpus)
-	go lp.runHeaps(lp.heaps)
+	for _, s := range lp.streams {
+		if s.disabled() {
+			continue
+		}
+		lp.wg.Add(1)
+		// Delays are computed here, rand is not safe for concurrent use.
+		go lp.run(lp.ctx, s, lp.opts.jitteredDelay(lp.rand))
+	}
 }
 
 func (lp *LP) stop() {
-	if lp.exit == nil {
+	if lp.ctx == nil {
 		return
 	}
 
-	close(lp.exit)
+	lp.cancel()
 
-	// Drain chan to avoid it blocking.
-	go func() {
-		for range lp.heaps {
-		}
-	}()
-	go func() {
-		for range lp.cpus {
-		}
-	}()
+	// Drain chan to avoid it blocking. Using local copies as
+	// fields are reset when closing, while those still run.
+	for _, s := range lp.streams {
+		go func(c chan Data) {
+			for range c {
+			}
+		}(s.out)
+	}
 
 	lp.wg.Wait()
-	lp.exit = nil
+	lp.ctx, lp.cancel = nil, nil
 }
 
 // Stop the profiler.
@@ -181,17 +373,19 @@ func (lp *LP) Stop() {
 }
 
 // Close the profiler. It can't be started again.
+// It waits until sinks have written all pending data.
 func (lp *LP) Close() {
 	lp.mu.Lock()
 	defer lp.mu.Unlock()
 
 	lp.stop()
 
-	lp.cpuCollector = nil
-	lp.heapCollector = nil
+	for _, s := range lp.streams {
+		if !s.disabled() {
+			close(s.out)
+		}
+	}
+	lp.streams = nil
 
-	close(lp.heaps)
-	lp.heaps = nil
-	close(lp.cpus)
-	lp.cpus = nil
+	lp.closeSinks()
 }
@@ -6,6 +6,7 @@
 package livepprof
 
 import (
+	"context"
 	"testing"
 	"time"
 
@@ -61,6 +62,11 @@ func TestLPBasic(t *testing.T) {
 		}
 	}
 	go f()
+	go func() {
+		for g := range a.Goroutine() {
+			t.Logf("%v", g)
+		}
+	}()
 
 	time.Sleep(time.Second)
 
@@ -69,3 +75,66 @@ func TestLPBasic(t *testing.T) {
 	assert.Equal(byte(0), buf2b[1])
 
 }
+
+func TestLPContext(t *testing.T) {
+	assert := assert.New(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	a, err := New(
+		WithFilter("livepprof"),
+		WithErrorHandler(func(err error) { assert.Nil(err) }),
+		WithDelay(time.Second/10),
+		WithContext(ctx),
+	)
+	assert.Nil(err)
+
+	// Nobody reads channels, cancelling the context must unblock them.
+	time.Sleep(time.Second / 2)
+	cancel()
+	done := make(chan struct{})
+	go func() {
+		a.Close()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		assert.Fail("close should not block once the context is done")
+	}
+}
+
+func TestLPStreams(t *testing.T) {
+	assert := assert.New(t)
+
+	a, err := New(
+		WithFilter("livepprof"),
+		WithErrorHandler(func(err error) { assert.Nil(err) }),
+		WithDelay(time.Second/10),
+		WithMutexFraction(10),
+	)
+	assert.Nil(err)
+	defer a.Close()
+
+	// Disabled by default, the channel is closed.
+	_, ok := <-a.Block()
+	assert.False(ok)
+
+	select {
+	case _, ok := <-a.Mutex():
+		assert.True(ok)
+	case <-time.After(5 * time.Second):
+		assert.Fail("no mutex data")
+	}
+
+	// Sending on a channel which has never been asked for does not block.
+	done := make(chan struct{})
+	go func() {
+		a.send(context.Background(), &stream{kind: KindGoroutine, out: make(chan Data)}, Data{})
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		assert.Fail("streams which are not read must not block")
+	}
+}
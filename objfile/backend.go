@@ -0,0 +1,47 @@
+// Live pprof is a Golang library to generate and use live profiles.
+// Copyright (C)  2018  Christian Mauduit <ufoot@ufoot.org>
+// Live pprof homepage: https://github.com/ufoot/livepprof
+// Contact author: ufoot@ufoot.org
+
+package objfile
+
+import (
+	"fmt"
+)
+
+// Backend is the implementation used to resolve addresses to source lines.
+type Backend int
+
+const (
+	// BackendAuto uses the native backend, and falls back on binutils
+	// for the addresses the native backend can not resolve.
+	BackendAuto Backend = iota
+	// BackendNative is pure Go, it uses the symbol tables the Go runtime
+	// embeds in every binary. It does not need any external tool, so it
+	// works in minimal (distroless, scratch...) containers.
+	BackendNative
+	// BackendBinutils uses GNU binutils (addr2line, nm...), they need
+	// to be installed on the host running the program.
+	BackendBinutils
+	// BackendProfile does not resolve anything, it uses the function and
+	// file names already in the profile, as runtime/pprof fills them.
+	// It handles inlined functions, and works whatever the binary is.
+	BackendProfile
+)
+
+var _ fmt.Stringer = BackendAuto
+
+// String returns a readable name for the backend.
+func (b Backend) String() string {
+	switch b {
+	case BackendAuto:
+		return "auto"
+	case BackendNative:
+		return "native"
+	case BackendBinutils:
+		return "binutils"
+	case BackendProfile:
+		return "profile"
+	}
+	return fmt.Sprintf("backend(%d)", int(b))
+}
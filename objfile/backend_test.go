@@ -0,0 +1,22 @@
+// Live pprof is a Golang library to generate and use live profiles.
+// Copyright (C)  2018  Christian Mauduit <ufoot@ufoot.org>
+// Live pprof homepage: https://github.com/ufoot/livepprof
+// Contact author: ufoot@ufoot.org
+
+package objfile
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackendString(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("auto", BackendAuto.String())
+	assert.Equal("native", BackendNative.String())
+	assert.Equal("binutils", BackendBinutils.String())
+	assert.Equal("profile", BackendProfile.String())
+	assert.Equal("backend(42)", Backend(42).String())
+}
@@ -0,0 +1,120 @@
+// Live pprof is a Golang library to generate and use live profiles.
+// Copyright (C)  2018  Christian Mauduit <ufoot@ufoot.org>
+// Live pprof homepage: https://github.com/ufoot/livepprof
+// Contact author: ufoot@ufoot.org
+
+package objfile
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/google/pprof/profile"
+
+	"github.com/ufoot/livepprof/internal/google/plugin"
+)
+
+// mappedFile is an object file, and the addresses it is mapped at.
+type mappedFile struct {
+	start   uint64
+	limit   uint64
+	objFile sourceLiner
+}
+
+// mapped resolves addresses with the object file they are mapped from,
+// typically the main executable and its shared libraries.
+type mapped struct {
+	name  string
+	files []mappedFile
+}
+
+var _ sourceLiner = &mapped{}
+
+// Name of the main binary file.
+func (m *mapped) Name() string {
+	return m.name
+}
+
+// SourceLine returns the frames for an address, inlined functions first.
+// Addresses which are not in any mapping return no frame.
+func (m *mapped) SourceLine(ctx context.Context, addr uint64) ([]plugin.Frame, error) {
+	for _, f := range m.files {
+		if addr >= f.start && addr < f.limit {
+			return f.objFile.SourceLine(ctx, addr)
+		}
+	}
+	return nil, nil
+}
+
+// Close closes the object files, and returns the first error.
+func (m *mapped) Close() error {
+	var ret error
+	for _, f := range m.files {
+		if c, ok := f.objFile.(io.Closer); ok {
+			if err := c.Close(); err != nil && ret == nil {
+				ret = err
+			}
+		}
+	}
+	return ret
+}
+
+// isPseudoFile tells wether a mapping is not a file, for instance [vdso].
+func isPseudoFile(name string) bool {
+	return name == "" || strings.HasPrefix(name, "[")
+}
+
+// OpenBinary returns an object file resolving addresses of another
+// program, typically to aggregate profiles fetched from other services,
+// or loaded from disk. The mappings are the ones from the profile, the
+// first one is the main executable, which is read from path, as the file
+// name in the mapping is only valid on the host the profile comes from.
+// Other mappings, shared libraries, are read from the file in the mapping,
+// if it can't be opened, their addresses are ignored. Without mappings,
+// path is considered mapped at its link address. Addresses are resolved
+// with binutils, which need to be installed. Unlike Open, this is not a
+// singleton, each call opens the files again, and starts addr2line
+// processes for them, so callers must call Close once they are done.
+func OpenBinary(path string, mappings []*profile.Mapping) (*ObjFile, error) {
+	globalMu.Lock()
+	defer globalMu.Unlock()
+
+	m := &mapped{name: path}
+	if len(mappings) == 0 {
+		f, err := globalBinutils.Open(path, 0, ^uint64(0), 0)
+		if err != nil {
+			return nil, err
+		}
+		m.files = append(m.files, mappedFile{start: 0, limit: ^uint64(0), objFile: &async{objFile: f}})
+	}
+	for i, mapping := range mappings {
+		name := mapping.File
+		if i == 0 {
+			name = path
+		} else if isPseudoFile(name) {
+			continue
+		}
+		// The base address, the difference between the addresses in the
+		// profile and the ones in the file, is computed from the mapping
+		// by binutils, with elfexec.GetBase.
+		f, err := globalBinutils.Open(name, mapping.Start, mapping.Limit, mapping.Offset)
+		if err != nil {
+			if i == 0 {
+				return nil, err
+			}
+			continue
+		}
+		m.files = append(m.files, mappedFile{start: mapping.Start, limit: mapping.Limit, objFile: &async{objFile: f}})
+	}
+
+	return &ObjFile{objFile: m, c: newCache(DefaultCacheCapacity), closer: m}, nil
+}
+
+// OpenProcess is like OpenBinary, for a process running on the same
+// host, its executable is read from /proc/<pid>/exe, so this works even
+// if the file has been replaced since the process started.
+func OpenProcess(pid int, mappings []*profile.Mapping) (*ObjFile, error) {
+	return OpenBinary(fmt.Sprintf("/proc/%d/exe", pid), mappings)
+}
@@ -0,0 +1,120 @@
+// Live pprof is a Golang library to generate and use live profiles.
+// Copyright (C)  2018  Christian Mauduit <ufoot@ufoot.org>
+// Live pprof homepage: https://github.com/ufoot/livepprof
+// Contact author: ufoot@ufoot.org
+
+package objfile
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"os/exec"
+	"runtime/pprof"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/google/pprof/profile"
+	"github.com/stretchr/testify/assert"
+)
+
+// selfProfile returns a goroutine profile of the test itself, and
+// the sample of the calling goroutine, found with the profile symbols.
+func selfProfile(t *testing.T) (*profile.Profile, *profile.Sample) {
+	var buf bytes.Buffer
+	if err := pprof.Lookup("goroutine").WriteTo(&buf, 0); err != nil {
+		t.Fatal(err)
+	}
+	gp, err := profile.Parse(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, sample := range gp.Sample {
+		for _, loc := range sample.Location {
+			for _, line := range loc.Line {
+				if line.Function != nil && strings.HasSuffix(line.Function.Name, ".selfProfile") {
+					return gp, sample
+				}
+			}
+		}
+	}
+	t.Fatal("no sample for the current goroutine")
+	return nil, nil
+}
+
+func TestOpenBinary(t *testing.T) {
+	assert := assert.New(t)
+
+	if _, err := exec.LookPath("addr2line"); err != nil {
+		t.Skip("addr2line not installed")
+	}
+	if _, err := exec.LookPath("llvm-symbolizer"); err == nil {
+		// It is preferred to addr2line, but recent versions reject
+		// the flags binutils passes, so nothing is resolved.
+		t.Skip("llvm-symbolizer installed")
+	}
+
+	gp, sample := selfProfile(t)
+	filter := &Filter{Include: []Pattern{Contains(FieldFunction, "TestOpenBinary")}}
+
+	exe, err := os.Executable()
+	assert.Nil(err)
+	of, err := OpenBinary(exe, gp.Mapping)
+	assert.Nil(err)
+	assert.Equal(exe, of.Name())
+	loc, err := of.ResolveSample(context.Background(), filter, sample)
+	assert.Nil(err)
+	if assert.NotNil(loc) {
+		assert.Equal("github.com/ufoot/livepprof/objfile.TestOpenBinary", loc.Function)
+	}
+	assert.Nil(of.Close())
+
+	of, err = OpenProcess(os.Getpid(), gp.Mapping)
+	assert.Nil(err)
+	loc, err = of.ResolveSample(context.Background(), filter, sample)
+	assert.Nil(err)
+	if assert.NotNil(loc) {
+		assert.Equal("github.com/ufoot/livepprof/objfile.TestOpenBinary", loc.Function)
+	}
+
+	assert.Nil(of.Close())
+
+	_, err = OpenBinary("/does/not/exist", gp.Mapping)
+	assert.NotNil(err)
+}
+
+func TestMapped(t *testing.T) {
+	assert := assert.New(t)
+
+	m := &mapped{name: "main", files: []mappedFile{
+		{start: 0x1000, limit: 0x2000, objFile: &async{objFile: &slowObjFile{unblock: closedChan()}}},
+	}}
+	frames, err := m.SourceLine(context.Background(), 0x1500)
+	assert.Nil(err)
+	assert.Equal(1, len(frames))
+	frames, err = m.SourceLine(context.Background(), 0x2000)
+	assert.Nil(err)
+	assert.Equal(0, len(frames))
+
+	slow := &slowObjFile{unblock: closedChan()}
+	m = &mapped{name: "main", files: []mappedFile{
+		{start: 0x1000, limit: 0x2000, objFile: &async{objFile: slow}},
+		{start: 0x2000, limit: 0x3000, objFile: newNative("native")},
+	}}
+	of := &ObjFile{objFile: m, c: newCache(DefaultCacheCapacity), closer: m}
+	assert.Nil(of.Close())
+	assert.Equal(int32(1), atomic.LoadInt32(&slow.closes))
+	assert.Nil((&ObjFile{objFile: m}).Close(), "singletons are not closed")
+	assert.Equal(int32(1), atomic.LoadInt32(&slow.closes))
+
+	assert.True(isPseudoFile("[vdso]"))
+	assert.True(isPseudoFile(""))
+	assert.False(isPseudoFile("/lib/libc.so.6"))
+}
+
+func closedChan() chan struct{} {
+	c := make(chan struct{})
+	close(c)
+	return c
+}
@@ -6,63 +6,129 @@
 package objfile
 
 import (
+	"container/list"
 	"encoding/binary"
-	"hash/fnv"
 	"sync"
 )
 
+// DefaultCacheCapacity is the default number of locations kept in cache.
+const DefaultCacheCapacity = 100000
+
+// CacheStats are counters about the location cache.
+type CacheStats struct {
+	// Hits is the number of locations found in cache.
+	Hits uint64
+	// Misses is the number of locations which had to be resolved.
+	Misses uint64
+	// Evictions is the number of locations removed to make room for others.
+	Evictions uint64
+	// Len is the number of locations in cache.
+	Len int
+	// Capacity is the maximum number of locations in cache.
+	Capacity int
+}
+
+type cacheItem struct {
+	key string
+	loc Location
+}
+
 // cache for locations, avoids resolving the same things over and over.
+// It is bounded, the least recently used locations are evicted first.
 type cache struct {
-	mu   sync.RWMutex
-	data map[uint64]Location
+	mu        sync.Mutex
+	capacity  int
+	items     map[string]*list.Element
+	lru       *list.List
+	hits      uint64
+	misses    uint64
+	evictions uint64
 }
 
-func cacheKey(addrs []uint64) uint64 {
-	h := fnv.New64()
-	buf := make([]byte, 8)
-	for _, addr := range addrs {
-		binary.LittleEndian.PutUint64(buf, addr)
-		_, err := h.Write(buf)
-		if err != nil {
-			return addr // really, should never happen
-		}
+// cacheKey is the exact content of the filter and the addresses,
+// unlike a hash, two different stacks can not have the same key.
+func cacheKey(filter string, addrs []uint64) string {
+	buf := make([]byte, binary.MaxVarintLen64, binary.MaxVarintLen64+len(filter)+8*len(addrs))
+	buf = append(buf[:binary.PutUvarint(buf, uint64(len(filter)))], filter...)
+	var addr [8]byte
+	for _, a := range addrs {
+		binary.LittleEndian.PutUint64(addr[:], a)
+		buf = append(buf, addr[:]...)
 	}
-	return h.Sum64()
+	return string(buf)
 }
 
-func newCache() *cache {
-	return &cache{}
+func newCache(capacity int) *cache {
+	return &cache{
+		capacity: capacity,
+		items:    make(map[string]*list.Element),
+		lru:      list.New(),
+	}
 }
 
-func (c *cache) set(addrs []uint64, l *Location) {
+// evict removes the least recently used locations until there are
+// no more than capacity. It must be called with the lock held.
+func (c *cache) evict() {
+	for c.lru.Len() > c.capacity {
+		e := c.lru.Back()
+		c.lru.Remove(e)
+		delete(c.items, e.Value.(*cacheItem).key)
+		c.evictions++
+	}
+}
+
+func (c *cache) set(key string, l *Location) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	if c.data == nil {
-		c.data = make(map[uint64]Location)
+	if e, ok := c.items[key]; ok {
+		e.Value.(*cacheItem).loc = *l
+		c.lru.MoveToFront(e)
+		return
 	}
-	key := cacheKey(addrs)
-	c.data[key] = *l
+	c.items[key] = c.lru.PushFront(&cacheItem{key: key, loc: *l})
+	c.evict()
 }
 
-func (c *cache) get(addrs []uint64) *Location {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
+func (c *cache) get(key string) *Location {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 
-	if c.data == nil {
-		return nil
-	}
-	key := cacheKey(addrs)
-	v, ok := c.data[key]
+	e, ok := c.items[key]
 	if !ok {
+		c.misses++
 		return nil
 	}
+	c.hits++
+	c.lru.MoveToFront(e)
+	v := e.Value.(*cacheItem).loc
 	return &v
 }
 
+func (c *cache) setCapacity(capacity int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.capacity = capacity
+	c.evict()
+}
+
+func (c *cache) stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return CacheStats{
+		Hits:      c.hits,
+		Misses:    c.misses,
+		Evictions: c.evictions,
+		Len:       c.lru.Len(),
+		Capacity:  c.capacity,
+	}
+}
+
 func (c *cache) len() int {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
+	c.mu.Lock()
+	defer c.mu.Unlock()
 
-	return len(c.data)
+	return c.lru.Len()
 }
@@ -14,17 +14,35 @@ import (
 func TestCacheKey(t *testing.T) {
 	assert := assert.New(t)
 
-	assert.Equal(uint64(0xcbf29ce484222325), cacheKey(nil))
-	assert.Equal(uint64(0xcbf29ce484222325), cacheKey([]uint64{}))
-	assert.Equal(uint64(0xe3757ca7d64666ea), cacheKey([]uint64{1}))
-	assert.Equal(uint64(0x0c4c250d07de76e8), cacheKey([]uint64{1, 3, 5}))
+	assert.Equal(cacheKey("", nil), cacheKey("", []uint64{}))
+	assert.Equal(cacheKey("f", []uint64{1, 3, 5}), cacheKey("f", []uint64{1, 3, 5}))
+	assert.NotEqual(cacheKey("", []uint64{1}), cacheKey("", []uint64{1, 0}))
+	assert.NotEqual(cacheKey("f", []uint64{1}), cacheKey("g", []uint64{1}))
+	assert.NotEqual(cacheKey("", []uint64{0x41}), cacheKey("A", nil), "filter and addresses must not be confused")
+	assert.Equal(1+1+8*3, len(cacheKey("f", []uint64{1, 3, 5})))
+}
+
+func TestFilterKey(t *testing.T) {
+	assert := assert.New(t)
+
+	// Same String, as patterns are joined with spaces, but different keys.
+	f1 := &Filter{Include: []Pattern{Contains(FieldFile, "a +file:b")}}
+	f2 := &Filter{Include: []Pattern{Contains(FieldFile, "a"), Contains(FieldFile, "b")}}
+	assert.Equal(f1.String(), f2.String())
+	assert.NotEqual(f1.key(), f2.key())
+
+	f3 := &Filter{Exclude: []Pattern{Contains(FieldFile, "a")}}
+	f4 := &Filter{Include: []Pattern{Contains(FieldFile, "a")}}
+	assert.NotEqual(f3.key(), f4.key())
+	assert.Equal("", (*Filter)(nil).key())
+	assert.Equal(f4.key(), (&Filter{Include: []Pattern{Contains(FieldFile, "a")}}).key())
 }
 
 func TestCache(t *testing.T) {
 	assert := assert.New(t)
 
-	key1 := []uint64{1}
-	key2 := []uint64{2, 3}
+	key1 := cacheKey("", []uint64{1})
+	key2 := cacheKey("", []uint64{2, 3})
 	l1 := Location{
 		Function: "function1",
 		File:     "file1",
@@ -36,7 +54,7 @@ func TestCache(t *testing.T) {
 		Stack:    "stack2",
 	}
 
-	c := newCache()
+	c := newCache(DefaultCacheCapacity)
 
 	assert.Nil(c.get(key1))
 	assert.Nil(c.get(key2))
@@ -60,4 +78,31 @@ func TestCache(t *testing.T) {
 	assert.NotNil(l5)
 	assert.Equal(l1, *l5)
 	assert.Equal(2, c.len())
+
+	assert.Equal(CacheStats{Hits: 3, Misses: 3, Len: 2, Capacity: DefaultCacheCapacity}, c.stats())
+}
+
+func TestCacheEviction(t *testing.T) {
+	assert := assert.New(t)
+
+	c := newCache(2)
+	key1 := cacheKey("", []uint64{1})
+	key2 := cacheKey("", []uint64{2})
+	key3 := cacheKey("", []uint64{3})
+	l := Location{Function: "function"}
+
+	c.set(key1, &l)
+	c.set(key2, &l)
+	assert.NotNil(c.get(key1)) // key2 is now the least recently used
+	c.set(key3, &l)
+	assert.Equal(2, c.len())
+	assert.Nil(c.get(key2))
+	assert.NotNil(c.get(key1))
+	assert.NotNil(c.get(key3))
+
+	c.setCapacity(1)
+	assert.Equal(1, c.len())
+	assert.NotNil(c.get(key3))
+	assert.Nil(c.get(key1))
+	assert.Equal(uint64(2), c.stats().Evictions)
 }
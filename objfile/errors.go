@@ -5,6 +5,10 @@
 
 package objfile
 
+import (
+	"fmt"
+)
+
 // NoArgs0Error when program name can't be found.
 type NoArgs0Error struct{}
 
@@ -36,3 +40,23 @@ type NilObjFileError struct{}
 func (e NilObjFileError) Error() string {
 	return "nil obj file"
 }
+
+// UnknownBackendError when the backend is not supported.
+type UnknownBackendError struct {
+	Backend Backend
+}
+
+// Error string.
+func (e UnknownBackendError) Error() string {
+	return "unknown backend: " + e.Backend.String()
+}
+
+// InvalidCacheCapacityError when the cache capacity is not usable.
+type InvalidCacheCapacityError struct {
+	Capacity int
+}
+
+// Error string.
+func (e InvalidCacheCapacityError) Error() string {
+	return fmt.Sprintf("invalid cache capacity: %d", e.Capacity)
+}
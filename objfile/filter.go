@@ -0,0 +1,191 @@
+// Live pprof is a Golang library to generate and use live profiles.
+// Copyright (C)  2018  Christian Mauduit <ufoot@ufoot.org>
+// Live pprof homepage: https://github.com/ufoot/livepprof
+// Contact author: ufoot@ufoot.org
+
+package objfile
+
+import (
+	"encoding/binary"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Field of a frame a pattern is matched against.
+type Field int
+
+const (
+	// FieldFile is the source file path.
+	FieldFile Field = iota
+	// FieldFunction is the full function name, as in
+	// github.com/me/mypackage.(*MyType).MyMethod
+	FieldFunction
+	// FieldPackage is the package path, as in github.com/me/mypackage.
+	FieldPackage
+)
+
+// String returns a readable name for the field.
+func (f Field) String() string {
+	switch f {
+	case FieldFile:
+		return "file"
+	case FieldFunction:
+		return "function"
+	case FieldPackage:
+		return "package"
+	}
+	return fmt.Sprintf("field(%d)", int(f))
+}
+
+// Pattern matches a field of a frame, either with a substring or a regexp.
+type Pattern struct {
+	// Field the pattern is matched against.
+	Field Field
+	// Substring the field must contain, only used if Regexp is nil.
+	Substring string
+	// Regexp the field must match.
+	Regexp *regexp.Regexp
+}
+
+// Contains returns a pattern matching fields which contain a substring.
+func Contains(field Field, substring string) Pattern {
+	return Pattern{Field: field, Substring: substring}
+}
+
+// MatchRegexp returns a pattern matching fields with a regexp.
+func MatchRegexp(field Field, expr string) (Pattern, error) {
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return Pattern{}, err
+	}
+	return Pattern{Field: field, Regexp: re}, nil
+}
+
+var (
+	// VendorPattern matches vendored code, my-package/vendor/github.com/other
+	// is probably not our code, so not a really interesting leaf.
+	VendorPattern = Contains(FieldFile, "/vendor/")
+	// GeneratedPattern matches the usual names of generated files.
+	GeneratedPattern = Pattern{Field: FieldFile, Regexp: regexp.MustCompile(`(\.pb\.go|\.pb\.gw\.go|_gen\.go|_generated\.go|_string\.go)$`)}
+	// MockPattern matches the usual names of mock packages.
+	MockPattern = Pattern{Field: FieldPackage, Regexp: regexp.MustCompile(`(^|/)mocks?($|/)|_mocks?$`)}
+)
+
+// packageName returns the package of a function. It does not work for
+// package paths which last element contains a dot, as in gopkg.in/yaml.v2,
+// there's no way to tell this from the function name only.
+func packageName(function string) string {
+	last := lastSlash(function)
+	dot := strings.Index(function[last+1:], ".")
+	if dot < 0 {
+		return function
+	}
+	return function[:last+1+dot]
+}
+
+// Match tells wether a frame, given by its function and file, matches.
+func (p Pattern) Match(function, file string) bool {
+	var s string
+	switch p.Field {
+	case FieldFile:
+		s = file
+	case FieldFunction:
+		s = function
+	case FieldPackage:
+		s = packageName(function)
+	default:
+		return false
+	}
+	if p.Regexp != nil {
+		return p.Regexp.MatchString(s)
+	}
+	return strings.Contains(s, p.Substring)
+}
+
+// String returns a readable representation of the pattern.
+func (p Pattern) String() string {
+	if p.Regexp != nil {
+		return p.Field.String() + "~" + p.Regexp.String()
+	}
+	return p.Field.String() + ":" + p.Substring
+}
+
+// Filter chooses the leaf on which to aggregate data. A frame is a leaf if
+// it matches any of the include patterns, and none of the exclude patterns.
+// Without include patterns, any frame which is not excluded is a leaf.
+// A nil filter considers any frame is a leaf.
+type Filter struct {
+	Include []Pattern
+	Exclude []Pattern
+}
+
+// NewFilter returns the filter used when a single string is given,
+// frames are leaves if their file contains it, and it is not vendored.
+func NewFilter(contains string) *Filter {
+	f := &Filter{Exclude: []Pattern{VendorPattern}}
+	if contains != "" {
+		f.Include = []Pattern{Contains(FieldFile, contains)}
+	}
+	return f
+}
+
+// Match tells wether a frame, given by its function and file, is a leaf.
+func (f *Filter) Match(function, file string) bool {
+	if f == nil {
+		return true
+	}
+	for _, p := range f.Exclude {
+		if p.Match(function, file) {
+			return false
+		}
+	}
+	if len(f.Include) == 0 {
+		return true
+	}
+	for _, p := range f.Include {
+		if p.Match(function, file) {
+			return true
+		}
+	}
+	return false
+}
+
+// String returns a readable representation of the filter, two filters
+// with the same patterns have the same representation.
+func (f *Filter) String() string {
+	if f == nil {
+		return ""
+	}
+	parts := make([]string, 0, len(f.Include)+len(f.Exclude))
+	for _, p := range f.Include {
+		parts = append(parts, "+"+p.String())
+	}
+	for _, p := range f.Exclude {
+		parts = append(parts, "-"+p.String())
+	}
+	return strings.Join(parts, " ")
+}
+
+// key returns a string which identifies the filter without ambiguity,
+// unlike String, each pattern is prefixed by its length, so that patterns
+// containing spaces can not be confused with several patterns.
+func (f *Filter) key() string {
+	if f == nil {
+		return ""
+	}
+	var buf []byte
+	add := func(sign byte, p Pattern) {
+		s := p.String()
+		buf = append(buf, sign)
+		buf = binary.AppendUvarint(buf, uint64(len(s)))
+		buf = append(buf, s...)
+	}
+	for _, p := range f.Include {
+		add('+', p)
+	}
+	for _, p := range f.Exclude {
+		add('-', p)
+	}
+	return string(buf)
+}
@@ -0,0 +1,81 @@
+// Live pprof is a Golang library to generate and use live profiles.
+// Copyright (C)  2018  Christian Mauduit <ufoot@ufoot.org>
+// Live pprof homepage: https://github.com/ufoot/livepprof
+// Contact author: ufoot@ufoot.org
+
+package objfile
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ufoot/livepprof/internal/google/plugin"
+)
+
+func TestPackageName(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("github.com/me/mypackage", packageName("github.com/me/mypackage.(*T).Method"))
+	assert.Equal("github.com/me/mypackage", packageName("github.com/me/mypackage.Func.func1"))
+	assert.Equal("main", packageName("main.main"))
+	assert.Equal("runtime", packageName("runtime.goexit"))
+	assert.Equal("nodot", packageName("nodot"))
+}
+
+func TestPattern(t *testing.T) {
+	assert := assert.New(t)
+
+	fn := "github.com/me/mypackage/mocks.(*Mock).Get"
+	file := "/src/github.com/me/mypackage/mocks/mock.go"
+
+	assert.True(Contains(FieldFile, "mypackage/mocks").Match(fn, file))
+	assert.False(Contains(FieldFile, "(*Mock)").Match(fn, file))
+	assert.True(Contains(FieldFunction, "(*Mock)").Match(fn, file))
+	assert.False(Contains(FieldPackage, "Get").Match(fn, file))
+	assert.True(MockPattern.Match(fn, file))
+	assert.False(MockPattern.Match("github.com/me/mocking.Func", "/src/mocking/a.go"))
+	assert.True(GeneratedPattern.Match("github.com/me/api.(*Req).Reset", "/src/api/api.pb.go"))
+	assert.False(GeneratedPattern.Match("github.com/me/api.Func", "/src/api/api.go"))
+
+	p, err := MatchRegexp(FieldPackage, `^github\.com/me/(mypackage|other)$`)
+	assert.Nil(err)
+	assert.True(p.Match("github.com/me/mypackage.Func", "a.go"))
+	assert.False(p.Match("github.com/me/mypackage/mocks.Func", "a.go"))
+	assert.Equal(`package~^github\.com/me/(mypackage|other)$`, p.String())
+
+	_, err = MatchRegexp(FieldFile, "(")
+	assert.NotNil(err)
+	assert.False(Pattern{Field: Field(42)}.Match(fn, file))
+}
+
+func TestFilter(t *testing.T) {
+	assert := assert.New(t)
+
+	var nilFilter *Filter
+	assert.True(nilFilter.Match("any", "thing"))
+	assert.Equal("", nilFilter.String())
+
+	f := NewFilter("mypackage")
+	assert.True(f.Match("github.com/me/mypackage.Func", "/src/github.com/me/mypackage/a.go"))
+	assert.False(f.Match("github.com/other.Func", "/src/github.com/me/mypackage/vendor/github.com/other/a.go"))
+	assert.False(f.Match("github.com/other.Func", "/src/github.com/other/a.go"))
+	assert.Equal("+file:mypackage -file:/vendor/", f.String())
+	assert.Equal("-file:/vendor/", NewFilter("").String())
+	assert.True(NewFilter("").Match("github.com/other.Func", "/src/github.com/other/a.go"))
+
+	// Several module roots in a monorepo, without their mocks.
+	f = &Filter{
+		Include: []Pattern{Contains(FieldPackage, "github.com/me/service1"), Contains(FieldPackage, "github.com/me/service2")},
+		Exclude: []Pattern{VendorPattern, MockPattern},
+	}
+	frames := []plugin.Frame{
+		{Func: "strings.Index", File: "/go/src/strings/strings.go"},
+		{Func: "github.com/me/service2/mocks.Get", File: "/src/service2/mocks/get.go"},
+		{Func: "github.com/me/service2/store.Get", File: "/src/service2/store/get.go"},
+		{Func: "github.com/me/service1.Handle", File: "/src/service1/handle.go"},
+	}
+	loc := locate(f, frames)
+	assert.Equal("github.com/me/service2/store.Get", loc.Function)
+	assert.Equal("service1.Handle/store.Get", loc.Stack)
+}
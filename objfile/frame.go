@@ -0,0 +1,171 @@
+// Live pprof is a Golang library to generate and use live profiles.
+// Copyright (C)  2018  Christian Mauduit <ufoot@ufoot.org>
+// Live pprof homepage: https://github.com/ufoot/livepprof
+// Contact author: ufoot@ufoot.org
+
+package objfile
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+const (
+	// frameSep separates frames in Frames, and fieldSep their fields.
+	// Those are ASCII record and unit separators, which are not in
+	// function names nor in file paths.
+	frameSep = "\x1e"
+	fieldSep = "\x1f"
+)
+
+// Frame is a function in a call stack.
+type Frame struct {
+	// Package path, such as "github.com/me/mypackage".
+	Package string `json:",omitempty"`
+	// Function, its complete name, with the package path.
+	Function string
+	// File where the function is.
+	File string `json:",omitempty"`
+}
+
+// Frames is a call stack, callers first, and the leaf last. Frames are
+// encoded in a string, so that it's comparable and locations can be used
+// as map keys, use Slice to get them. The encoding is stable, the string
+// itself is a key for the stack. Frames are separated by an ASCII record
+// separator (\x1e), and within a frame, the package, function and file are
+// separated by an ASCII unit separator (\x1f). In JSON, Frames is an array
+// of Frame.
+type Frames string
+
+// NewFrames encodes frames, callers first, and the leaf last.
+func NewFrames(frames []Frame) Frames {
+	var sb strings.Builder
+	for i, f := range frames {
+		if i > 0 {
+			sb.WriteString(frameSep)
+		}
+		sb.WriteString(f.Package)
+		sb.WriteString(fieldSep)
+		sb.WriteString(f.Function)
+		sb.WriteString(fieldSep)
+		sb.WriteString(f.File)
+	}
+	return Frames(sb.String())
+}
+
+// Slice returns the frames, callers first, and the leaf last.
+func (f Frames) Slice() []Frame {
+	if f == "" {
+		return nil
+	}
+	parts := strings.Split(string(f), frameSep)
+	ret := make([]Frame, len(parts))
+	for i, part := range parts {
+		fields := strings.SplitN(part, fieldSep, 3)
+		for len(fields) < 3 {
+			fields = append(fields, "")
+		}
+		ret[i] = Frame{Package: fields[0], Function: fields[1], File: fields[2]}
+	}
+	return ret
+}
+
+// Len returns the number of frames.
+func (f Frames) Len() int {
+	if f == "" {
+		return 0
+	}
+	return strings.Count(string(f), frameSep) + 1
+}
+
+// Last returns the last n frames, that is, the leaf and n-1 callers.
+func (f Frames) Last(n int) Frames {
+	if n <= 0 {
+		return ""
+	}
+	s := string(f)
+	for i := len(s) - 1; i >= 0; i-- {
+		if s[i] == frameSep[0] {
+			n--
+			if n == 0 {
+				return Frames(s[i+1:])
+			}
+		}
+	}
+	return f
+}
+
+// Stack returns the frames in the legacy format of Location.Stack,
+// the function names without their package path, joined with "/".
+func (f Frames) Stack() string {
+	frames := f.Slice()
+	funcs := make([]string, len(frames))
+	for i, frame := range frames {
+		funcs[i] = funcOnly(frame.Function)
+	}
+	return strings.Join(funcs, "/")
+}
+
+// String returns the frames in the legacy format, see Stack.
+func (f Frames) String() string {
+	return f.Stack()
+}
+
+// MarshalJSON encodes frames as an array of Frame.
+func (f Frames) MarshalJSON() ([]byte, error) {
+	frames := f.Slice()
+	if frames == nil {
+		frames = []Frame{}
+	}
+	return json.Marshal(frames)
+}
+
+// UnmarshalJSON decodes frames from an array of Frame.
+func (f *Frames) UnmarshalJSON(data []byte) error {
+	var frames []Frame
+	if err := json.Unmarshal(data, &frames); err != nil {
+		return err
+	}
+	*f = NewFrames(frames)
+	return nil
+}
+
+// SplitStack splits a stack in the legacy format of Location.Stack, into
+// function names. Type parameters, in brackets, are not split, even if
+// they contain package paths.
+func SplitStack(stack string) []string {
+	if stack == "" {
+		return nil
+	}
+	var ret []string
+	depth, start := 0, 0
+	for i := 0; i < len(stack); i++ {
+		switch stack[i] {
+		case '[':
+			depth++
+		case ']':
+			if depth > 0 {
+				depth--
+			}
+		case '/':
+			if depth == 0 {
+				ret = append(ret, stack[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(ret, stack[start:])
+}
+
+// ParseStack converts a stack in the legacy format of Location.Stack into
+// frames. The legacy format drops package paths, only their last element
+// is left, so the Package of frames is that last element, and there are
+// no files.
+func ParseStack(stack string) Frames {
+	funcs := SplitStack(stack)
+	frames := make([]Frame, len(funcs))
+	for i, fn := range funcs {
+		frames[i] = Frame{Package: packageName(fn), Function: fn}
+	}
+	return NewFrames(frames)
+}
@@ -0,0 +1,63 @@
+// Live pprof is a Golang library to generate and use live profiles.
+// Copyright (C)  2018  Christian Mauduit <ufoot@ufoot.org>
+// Live pprof homepage: https://github.com/ufoot/livepprof
+// Contact author: ufoot@ufoot.org
+
+package objfile
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFrames(t *testing.T) {
+	assert := assert.New(t)
+
+	frames := []Frame{
+		{Package: "main", Function: "main.main", File: "/src/github.com/me/mycommand/main.go"},
+		{Package: "github.com/me/mypackage", Function: "github.com/me/mypackage.Map[go.shape.struct { github.com/other/pkg.T }]", File: "/src/github.com/me/mypackage/map.go"},
+		{Package: "github.com/other/mypackage", Function: "github.com/other/mypackage.F"},
+	}
+	f := NewFrames(frames)
+	assert.Equal(frames, f.Slice())
+	assert.Equal(3, f.Len())
+	assert.Equal(frames[1:], f.Last(2).Slice())
+	assert.Equal(f, f.Last(10))
+	assert.Equal(Frames(""), f.Last(0))
+	assert.Equal("main.main/mypackage.Map[go.shape.struct { github.com/other/pkg.T }]/mypackage.F", f.Stack())
+
+	// Same short names, different packages, different keys.
+	other := NewFrames([]Frame{frames[0], frames[1], {Package: "github.com/me/mypackage", Function: "github.com/me/mypackage.F"}})
+	assert.Equal(f.Stack(), other.Stack())
+	assert.NotEqual(f, other)
+
+	js, err := json.Marshal(Location{Function: "f", Frames: f.Last(1)})
+	assert.Nil(err)
+	assert.Equal(`{"Function":"f","File":"","Stack":"","Frames":[{"Package":"github.com/other/mypackage","Function":"github.com/other/mypackage.F"}]}`, string(js))
+	var loc Location
+	assert.Nil(json.Unmarshal(js, &loc))
+	assert.Equal(f.Last(1), loc.Frames)
+
+	var empty Frames
+	assert.Nil(empty.Slice())
+	assert.Equal(0, empty.Len())
+	js, err = json.Marshal(Location{})
+	assert.Nil(err)
+	assert.Equal(`{"Function":"","File":"","Stack":""}`, string(js))
+}
+
+func TestParseStack(t *testing.T) {
+	assert := assert.New(t)
+
+	stack := "main.main/mypackage.Map[go.shape.struct { github.com/other/pkg.T }]/mypackage.(*T).F"
+	assert.Equal([]string{"main.main", "mypackage.Map[go.shape.struct { github.com/other/pkg.T }]", "mypackage.(*T).F"}, SplitStack(stack))
+	assert.Nil(SplitStack(""))
+	assert.Equal([]Frame{
+		{Package: "main", Function: "main.main"},
+		{Package: "mypackage", Function: "mypackage.Map[go.shape.struct { github.com/other/pkg.T }]"},
+		{Package: "mypackage", Function: "mypackage.(*T).F"},
+	}, ParseStack(stack).Slice())
+	assert.Equal(stack, ParseStack(stack).Stack())
+}
@@ -0,0 +1,90 @@
+// Live pprof is a Golang library to generate and use live profiles.
+// Copyright (C)  2018  Christian Mauduit <ufoot@ufoot.org>
+// Live pprof homepage: https://github.com/ufoot/livepprof
+// Contact author: ufoot@ufoot.org
+
+package objfile
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Granularity of the aggregation key, see Key.
+type Granularity int
+
+const (
+	// GranularityStack keeps the function, its file, and the full stack
+	// which leads to it. This is the default.
+	GranularityStack Granularity = iota
+	// GranularityFunction keeps the function and its file only.
+	GranularityFunction
+	// GranularityLine keeps the function, its file, and the line.
+	GranularityLine
+	// GranularityPackage keeps the package of the function only,
+	// it is reported as the function.
+	GranularityPackage
+	// GranularityFile keeps the file only.
+	GranularityFile
+	// GranularityCallers keeps the function, its file, and a given
+	// number of callers in the stack.
+	GranularityCallers
+)
+
+// String returns a readable name for the granularity.
+func (g Granularity) String() string {
+	switch g {
+	case GranularityStack:
+		return "stack"
+	case GranularityFunction:
+		return "function"
+	case GranularityLine:
+		return "line"
+	case GranularityPackage:
+		return "package"
+	case GranularityFile:
+		return "file"
+	case GranularityCallers:
+		return "callers"
+	}
+	return fmt.Sprintf("granularity(%d)", int(g))
+}
+
+// Key tells how data is aggregated, that is, which fields of the location
+// are kept. Finer keys give more details, but more entries, which can be
+// a problem for the systems data is sent to. The zero value aggregates
+// on the function and the full stack.
+type Key struct {
+	Granularity Granularity
+	// Callers is the number of callers kept, with GranularityCallers.
+	Callers int
+}
+
+// Valid tells wether the key can be used.
+func (k Key) Valid() bool {
+	if k.Granularity < GranularityStack || k.Granularity > GranularityCallers {
+		return false
+	}
+	return k.Callers >= 0
+}
+
+// Apply returns the location, with only the fields the key keeps.
+func (k Key) Apply(loc Location) Location {
+	switch k.Granularity {
+	case GranularityFunction:
+		return Location{Function: loc.Function, File: loc.File}
+	case GranularityLine:
+		return Location{Function: loc.Function, File: loc.File, Line: loc.Line}
+	case GranularityPackage:
+		return Location{Function: packageName(loc.Function)}
+	case GranularityFile:
+		return Location{File: loc.File}
+	case GranularityCallers:
+		funcs := SplitStack(loc.Stack)
+		if len(funcs) > k.Callers+1 {
+			funcs = funcs[len(funcs)-k.Callers-1:]
+		}
+		return Location{Function: loc.Function, File: loc.File, Stack: strings.Join(funcs, "/"), Frames: loc.Frames.Last(k.Callers + 1)}
+	}
+	return Location{Function: loc.Function, File: loc.File, Stack: loc.Stack, Frames: loc.Frames}
+}
@@ -0,0 +1,45 @@
+// Live pprof is a Golang library to generate and use live profiles.
+// Copyright (C)  2018  Christian Mauduit <ufoot@ufoot.org>
+// Live pprof homepage: https://github.com/ufoot/livepprof
+// Contact author: ufoot@ufoot.org
+
+package objfile
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKey(t *testing.T) {
+	assert := assert.New(t)
+
+	loc := Location{
+		Function: "github.com/me/mypackage.(*T).Method",
+		File:     "/src/github.com/me/mypackage/t.go",
+		Stack:    "main.main/mypackage.Run/mypackage.(*T).Method",
+		Frames: NewFrames([]Frame{
+			{Package: "main", Function: "main.main", File: "/src/github.com/me/mycommand/main.go"},
+			{Package: "github.com/me/mypackage", Function: "github.com/me/mypackage.Run", File: "/src/github.com/me/mypackage/run.go"},
+			{Package: "github.com/me/mypackage", Function: "github.com/me/mypackage.(*T).Method", File: "/src/github.com/me/mypackage/t.go"},
+		}),
+		Line: 42,
+	}
+
+	assert.Equal(Location{Function: loc.Function, File: loc.File, Stack: loc.Stack, Frames: loc.Frames}, Key{}.Apply(loc))
+	assert.Equal(Location{Function: loc.Function, File: loc.File}, Key{Granularity: GranularityFunction}.Apply(loc))
+	assert.Equal(Location{Function: loc.Function, File: loc.File, Line: 42}, Key{Granularity: GranularityLine}.Apply(loc))
+	assert.Equal(Location{Function: "github.com/me/mypackage"}, Key{Granularity: GranularityPackage}.Apply(loc))
+	assert.Equal(Location{File: loc.File}, Key{Granularity: GranularityFile}.Apply(loc))
+	assert.Equal("mypackage.(*T).Method", Key{Granularity: GranularityCallers}.Apply(loc).Stack)
+	assert.Equal("mypackage.Run/mypackage.(*T).Method", Key{Granularity: GranularityCallers, Callers: 1}.Apply(loc).Stack)
+	assert.Equal(loc.Frames.Slice()[1:], Key{Granularity: GranularityCallers, Callers: 1}.Apply(loc).Frames.Slice())
+	assert.Equal(loc.Stack, Key{Granularity: GranularityCallers, Callers: 10}.Apply(loc).Stack)
+
+	assert.True(Key{}.Valid())
+	assert.True(Key{Granularity: GranularityCallers, Callers: 3}.Valid())
+	assert.False(Key{Granularity: GranularityCallers, Callers: -1}.Valid())
+	assert.False(Key{Granularity: Granularity(42)}.Valid())
+	assert.Equal("line", GranularityLine.String())
+	assert.Equal("granularity(42)", Granularity(42).String())
+}
@@ -9,20 +9,28 @@ import (
 	"encoding/json"
 	"fmt"
 	"strings"
+
+	"github.com/ufoot/livepprof/internal/google/plugin"
 )
 
 // Location identifies a place in the code. It is used to aggregate data.
 // It does not have the uint64 address or the file line because this would
 // lead to high cardinality and, for instance, different points of the same
 // function would be counted in different entries. OTOH the stack trace is
-// considered a key field, to know where the call comes from.
+// considered a key field, to know where the call comes from. This is the
+// default, other choices can be made with Key.
 type Location struct {
 	// Function from where the call was done.
 	Function string
 	// File where the function is.
 	File string
-	// Stack is a call stack that stops at function. Using "/" to separate functions.
+	// Stack is a call stack that stops at function. Using "/" to separate functions,
+	// and package paths are dropped, see Frames for the complete call stack.
 	Stack string
+	// Frames is the same call stack as Stack, with package paths and files.
+	Frames Frames `json:",omitempty"`
+	// Line in the file, only set with GranularityLine, see Key.
+	Line int `json:",omitempty"`
 }
 
 var _ fmt.Stringer = &Location{}
@@ -39,10 +47,53 @@ func (loc *Location) String() string {
 	return string(js)
 }
 
+// lastSlash returns the index of the last "/" of the package path of a
+// function, -1 if none. Type parameters may contain paths, they are ignored.
+func lastSlash(f string) int {
+	if i := strings.IndexByte(f, '['); i >= 0 {
+		f = f[:i]
+	}
+	return strings.LastIndex(f, "/")
+}
+
 func funcOnly(f string) string {
-	li := strings.LastIndex(f, "/")
-	if li < 0 {
-		return f
+	return f[lastSlash(f)+1:]
+}
+
+// locate builds a location from frames, the leaf function first, and callers after.
+// The leaf is the first frame matching the filter, the first frame if none does.
+func locate(filter *Filter, frames []plugin.Frame) Location {
+	var leaf int
+	for i, frame := range frames {
+		if filter.Match(frame.Func, frame.File) {
+			leaf = i
+			break
+		}
 	}
-	return f[li+1:]
+
+	n := len(frames) - leaf
+	funcs := make([]string, 0, n)
+	frs := make([]Frame, 0, n)
+	// Starting at len(frames)-2 if len(frames)-1 is runtime.goexit, not interesting.
+	// Recent Go versions do not report it any more, so it's not always there.
+	i0 := len(frames) - 1
+	if i0 > 0 && frames[i0].Func == "runtime.goexit" {
+		i0--
+	}
+
+	loc := Location{}
+	for i := i0; i >= leaf; i-- {
+		funcs = append(funcs, funcOnly(frames[i].Func))
+		frs = append(frs, Frame{Package: packageName(frames[i].Func), Function: frames[i].Func, File: frames[i].File})
+		if i == leaf {
+			loc.Function = frames[i].Func
+			loc.File = frames[i].File
+			loc.Line = frames[i].Line
+		}
+	}
+
+	loc.Stack = strings.Join(funcs, "/")
+	loc.Frames = NewFrames(frs)
+
+	return loc
 }
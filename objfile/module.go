@@ -0,0 +1,72 @@
+// Live pprof is a Golang library to generate and use live profiles.
+// Copyright (C)  2018  Christian Mauduit <ufoot@ufoot.org>
+// Live pprof homepage: https://github.com/ufoot/livepprof
+// Contact author: ufoot@ufoot.org
+
+package objfile
+
+import (
+	"path/filepath"
+	"regexp"
+	"runtime/debug"
+	"strings"
+)
+
+// NoBuildInfoError when the binary has no module information,
+// typically because it was not built with modules.
+type NoBuildInfoError struct{}
+
+// Error string.
+func (e NoBuildInfoError) Error() string {
+	return "no build info"
+}
+
+// ModulePattern matches the packages of a module, given by its path.
+func ModulePattern(path string) Pattern {
+	return Pattern{Field: FieldPackage, Regexp: regexp.MustCompile("^" + regexp.QuoteMeta(path) + "($|/)")}
+}
+
+// isLocalReplace tells wether a module is replaced by a directory, and not
+// by another module. Such modules are typically part of the same repository.
+func isLocalReplace(m *debug.Module) bool {
+	if m == nil || m.Replace == nil || m.Replace.Version != "" {
+		return false
+	}
+	p := m.Replace.Path
+	return filepath.IsAbs(p) || strings.HasPrefix(p, "./") || strings.HasPrefix(p, "../")
+}
+
+// mainPattern matches package main, whose symbols are reported as main.xxx
+// and not under the path of the main module.
+var mainPattern = Pattern{Field: FieldFunction, Regexp: regexp.MustCompile(`^main\.`)}
+
+// moduleFilter returns a filter matching the main module of a build,
+// its main package, and the modules it replaces by local directories.
+func moduleFilter(info *debug.BuildInfo) (*Filter, error) {
+	if info == nil || info.Main.Path == "" {
+		return nil, NoBuildInfoError{}
+	}
+
+	f := &Filter{Include: []Pattern{ModulePattern(info.Main.Path), mainPattern}}
+	for _, dep := range info.Deps {
+		if isLocalReplace(dep) {
+			f.Include = append(f.Include, ModulePattern(dep.Path))
+		}
+	}
+	return f, nil
+}
+
+// MainModuleFilter returns a filter matching the code of the main module,
+// including package main, as reported by runtime/debug.ReadBuildInfo, and
+// of the modules replaced by a local directory, typically other modules of
+// the same repository. Matching is done on package paths, so it works with
+// -trimpath builds, and the module cache, as file paths do not matter.
+// Vendored packages keep their own package path, so they are not matched
+// either.
+func MainModuleFilter() (*Filter, error) {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return nil, NoBuildInfoError{}
+	}
+	return moduleFilter(info)
+}
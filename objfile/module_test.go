@@ -0,0 +1,50 @@
+// Live pprof is a Golang library to generate and use live profiles.
+// Copyright (C)  2018  Christian Mauduit <ufoot@ufoot.org>
+// Live pprof homepage: https://github.com/ufoot/livepprof
+// Contact author: ufoot@ufoot.org
+
+package objfile
+
+import (
+	"runtime/debug"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestModuleFilter(t *testing.T) {
+	assert := assert.New(t)
+
+	info := &debug.BuildInfo{
+		Main: debug.Module{Path: "github.com/me/service"},
+		Deps: []*debug.Module{
+			{Path: "github.com/other/lib", Version: "v1.2.3"},
+			{Path: "github.com/me/shared", Version: "v0.0.0", Replace: &debug.Module{Path: "../shared"}},
+			{Path: "github.com/other/fork", Version: "v1.0.0", Replace: &debug.Module{Path: "github.com/me/fork", Version: "v1.0.1"}},
+		},
+	}
+	f, err := moduleFilter(info)
+	assert.Nil(err)
+	assert.Equal(3, len(f.Include))
+
+	// File paths do not matter, only package paths.
+	assert.True(f.Match("github.com/me/service.main", "github.com/me/service/main.go"))
+	assert.True(f.Match("github.com/me/service/store.(*DB).Get", "/go/pkg/mod/cache/whatever.go"))
+	assert.True(f.Match("main.main", "/src/github.com/me/service/cmd/service/main.go"))
+	assert.True(f.Match("github.com/me/shared/log.Print", "../shared/log/print.go"))
+	assert.False(f.Match("github.com/me/service2.main", "/src/github.com/me/service/main.go"))
+	assert.False(f.Match("github.com/other/lib.Func", "/go/pkg/mod/github.com/other/lib@v1.2.3/lib.go"))
+	assert.False(f.Match("github.com/other/fork.Func", "/go/pkg/mod/github.com/me/fork@v1.0.1/fork.go"))
+
+	_, err = moduleFilter(&debug.BuildInfo{})
+	assert.Equal(NoBuildInfoError{}, err)
+
+	if info, ok := debug.ReadBuildInfo(); !ok || info.Main.Path == "" {
+		t.Skip("no build info, binary not built with modules")
+	}
+	// Test binaries have the module of the tested package as main module.
+	f, err = MainModuleFilter()
+	assert.Nil(err)
+	assert.True(f.Match("github.com/ufoot/livepprof/objfile.TestModuleFilter", "module_test.go"))
+	assert.False(f.Match("testing.tRunner", "/go/src/testing/testing.go"))
+}
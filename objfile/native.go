@@ -0,0 +1,171 @@
+// Live pprof is a Golang library to generate and use live profiles.
+// Copyright (C)  2018  Christian Mauduit <ufoot@ufoot.org>
+// Live pprof homepage: https://github.com/ufoot/livepprof
+// Contact author: ufoot@ufoot.org
+
+package objfile
+
+import (
+	"context"
+	"runtime"
+	"sync"
+
+	"github.com/ufoot/livepprof/internal/google/plugin"
+)
+
+// native resolves addresses of the current program using the pclntab
+// tables the Go runtime embeds in every binary. This is exactly what
+// runtime/pprof does when it symbolizes profiles, so it works even
+// on stripped binaries, and does not need to read anything on disk.
+type native struct {
+	name string
+}
+
+var _ sourceLiner = &native{}
+
+func newNative(name string) *native {
+	return &native{name: name}
+}
+
+// Name of the binary file.
+func (n *native) Name() string {
+	return n.name
+}
+
+// SourceLine returns the frames for an address, inlined functions first.
+// Addresses which are not Go code (typically, cgo) return no frame.
+func (n *native) SourceLine(ctx context.Context, addr uint64) ([]plugin.Frame, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var ret []plugin.Frame
+
+	frames := runtime.CallersFrames([]uintptr{uintptr(addr)})
+	for {
+		frame, more := frames.Next()
+		if frame.Function != "" {
+			ret = append(ret, plugin.Frame{
+				Func: frame.Function,
+				File: frame.File,
+				Line: frame.Line,
+			})
+		}
+		if !more {
+			break
+		}
+	}
+
+	return ret, nil
+}
+
+// fallback tries to resolve addresses with a primary source liner,
+// and uses the secondary one if nothing is found.
+type fallback struct {
+	primary   sourceLiner
+	secondary sourceLiner
+}
+
+var _ sourceLiner = &fallback{}
+
+// Name of the binary file.
+func (f *fallback) Name() string {
+	return f.primary.Name()
+}
+
+// SourceLine returns the frames for an address, inlined functions first.
+func (f *fallback) SourceLine(ctx context.Context, addr uint64) ([]plugin.Frame, error) {
+	frames, err := f.primary.SourceLine(ctx, addr)
+	if err != nil && ctx.Err() != nil {
+		return nil, err
+	}
+	if (err != nil || len(frames) < 1) && f.secondary != nil {
+		return f.secondary.SourceLine(ctx, addr)
+	}
+	return frames, err
+}
+
+// async wraps an object file which can be slow to resolve addresses,
+// typically binutils which runs addr2line in another process, so that
+// callers do not wait for it once their context is done. Requests are
+// queued, and handled one at a time by a single worker, which only runs
+// when there are requests, so abandoned requests do not pile up goroutines.
+type async struct {
+	objFile plugin.ObjFile
+
+	mu      sync.Mutex
+	queue   []*sourceLineRequest
+	running bool
+}
+
+var _ sourceLiner = &async{}
+
+type sourceLineRequest struct {
+	ctx  context.Context
+	addr uint64
+	c    chan sourceLineResult
+}
+
+type sourceLineResult struct {
+	frames []plugin.Frame
+	err    error
+}
+
+// Name of the binary file.
+func (a *async) Name() string {
+	return a.objFile.Name()
+}
+
+// Close closes the object file.
+func (a *async) Close() error {
+	return a.objFile.Close()
+}
+
+// SourceLine returns the frames for an address, inlined functions first.
+// If ctx is done before the answer, a request which is still queued is
+// skipped, one which is running goes on in the background, addr2line
+// serializes requests, so this does not corrupt its state.
+func (a *async) SourceLine(ctx context.Context, addr uint64) ([]plugin.Frame, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	req := &sourceLineRequest{ctx: ctx, addr: addr, c: make(chan sourceLineResult, 1)}
+	a.mu.Lock()
+	a.queue = append(a.queue, req)
+	if !a.running {
+		a.running = true
+		go a.work()
+	}
+	a.mu.Unlock()
+
+	select {
+	case r := <-req.c:
+		return r.frames, r.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// work handles queued requests, and returns once there are none left.
+func (a *async) work() {
+	for {
+		a.mu.Lock()
+		if len(a.queue) == 0 {
+			a.running = false
+			a.mu.Unlock()
+			return
+		}
+		req := a.queue[0]
+		a.queue[0] = nil
+		a.queue = a.queue[1:]
+		a.mu.Unlock()
+
+		if err := req.ctx.Err(); err != nil {
+			req.c <- sourceLineResult{err: err}
+			continue
+		}
+		frames, err := a.objFile.SourceLine(req.addr)
+		req.c <- sourceLineResult{frames: frames, err: err}
+	}
+}
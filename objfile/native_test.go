@@ -0,0 +1,78 @@
+// Live pprof is a Golang library to generate and use live profiles.
+// Copyright (C)  2018  Christian Mauduit <ufoot@ufoot.org>
+// Live pprof homepage: https://github.com/ufoot/livepprof
+// Contact author: ufoot@ufoot.org
+
+package objfile
+
+import (
+	"context"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ufoot/livepprof/internal/google/plugin"
+)
+
+// slowObjFile only implements SourceLine, and waits for unblock to answer.
+type slowObjFile struct {
+	plugin.ObjFile
+	unblock chan struct{}
+	calls   int32
+	closes  int32
+}
+
+func (s *slowObjFile) Close() error {
+	atomic.AddInt32(&s.closes, 1)
+	return nil
+}
+
+func (s *slowObjFile) SourceLine(addr uint64) ([]plugin.Frame, error) {
+	atomic.AddInt32(&s.calls, 1)
+	<-s.unblock
+	return []plugin.Frame{{Func: "main.slow", File: "/src/main.go", Line: 1}}, nil
+}
+
+func TestAsync(t *testing.T) {
+	assert := assert.New(t)
+
+	a := &async{objFile: &slowObjFile{unblock: make(chan struct{})}}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second/10)
+	defer cancel()
+	frames, err := a.SourceLine(ctx, 0x1000)
+	assert.Equal(context.DeadlineExceeded, err)
+	assert.Nil(frames)
+
+	close(a.objFile.(*slowObjFile).unblock)
+	frames, err = a.SourceLine(context.Background(), 0x1000)
+	assert.Nil(err)
+	assert.Equal("main.slow", frames[0].Func)
+}
+
+func TestAsyncWorker(t *testing.T) {
+	assert := assert.New(t)
+
+	slow := &slowObjFile{unblock: make(chan struct{})}
+	a := &async{objFile: slow}
+
+	goroutines := runtime.NumGoroutine()
+	for i := 0; i < 100; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+		_, err := a.SourceLine(ctx, 0x1000)
+		cancel()
+		assert.Equal(context.DeadlineExceeded, err)
+	}
+	// Only one worker, blocked on the first request.
+	assert.True(runtime.NumGoroutine() <= goroutines+1, "requests should not start goroutines")
+
+	close(slow.unblock)
+	frames, err := a.SourceLine(context.Background(), 0x1000)
+	assert.Nil(err)
+	assert.Equal("main.slow", frames[0].Func)
+	// Abandoned requests, still queued, are skipped.
+	assert.True(atomic.LoadInt32(&slow.calls) <= 2, "abandoned requests should be skipped")
+}
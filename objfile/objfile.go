@@ -6,16 +6,19 @@
 package objfile
 
 import (
-	"strings"
+	"context"
+	"io"
 	"sync"
 
+	"github.com/google/pprof/profile"
+
 	"github.com/ufoot/livepprof/internal/google/binutils"
 	"github.com/ufoot/livepprof/internal/google/plugin"
 )
 
 var globalMu sync.Mutex
 var globalBinutils binutils.Binutils
-var globalObjFile *ObjFile
+var globalObjFiles = make(map[Backend]*ObjFile)
 
 // Resolver resolves addresses to locations.
 type Resolver interface {
@@ -27,37 +30,117 @@ type Resolver interface {
 	Resolve(contains string, addrs []uint64) (*Location, error)
 }
 
+// ContextResolver is a resolver which can use a filter, and be cancelled
+// with a context.
+type ContextResolver interface {
+	Resolver
+	// ResolveContext is like Resolve, but uses a filter to find the leaf,
+	// returns all the fields of the location, and stops if ctx is done.
+	ResolveContext(ctx context.Context, filter *Filter, addrs []uint64) (*Location, error)
+}
+
+// SampleResolver resolves profile samples to locations.
+type SampleResolver interface {
+	// ResolveSample finds the location of a sample, it stops if ctx is done.
+	// The filter is used to find the leaf on which to aggregate data.
+	// The location has all its fields, see Key.Apply.
+	ResolveSample(ctx context.Context, filter *Filter, sample *profile.Sample) (*Location, error)
+}
+
+// NewSampleResolver returns a sample resolver for a given backend.
+// With BackendProfile, symbols are read from the profile itself,
+// else addresses are resolved with the global object file, see Open.
+func NewSampleResolver(backend Backend) (SampleResolver, error) {
+	if backend == BackendProfile {
+		return NewProfileResolver(), nil
+	}
+	return Open(backend)
+}
+
+// sourceLiner is the part of plugin.ObjFile needed to resolve addresses,
+// with a context so that slow resolutions can be cancelled.
+type sourceLiner interface {
+	// Name returns the underlying file name, if available.
+	Name() string
+	// SourceLine reports the source line information for a given
+	// address, with the leaf function first.
+	SourceLine(ctx context.Context, addr uint64) ([]plugin.Frame, error)
+}
+
 // ObjFile is an object file representation, used to resolve addresses.
 type ObjFile struct {
-	objFile plugin.ObjFile
-	c       cache
+	objFile sourceLiner
+	c       *cache
+	// closer releases the files opened by OpenBinary, the singletons
+	// returned by Open are shared, so they are never closed.
+	closer io.Closer
 }
 
-var _ Resolver = &ObjFile{}
+var _ ContextResolver = &ObjFile{}
+var _ SampleResolver = &ObjFile{}
 
 // New returns a global object allowing to resolve addresses to locations.
 // This is a slingleton, and reports data only for self, the current program
-// identified by os.Args[0]. When testing, go test tools do not embed symbols
-// by default, you need to explicitly use `go test -o filename` else this
-// will fail and not be able to get the info.
+// identified by os.Args[0]. It uses the auto backend, see Open.
 func New() (*ObjFile, error) {
+	return Open(BackendAuto)
+}
+
+// Open returns a global object allowing to resolve addresses to locations,
+// using a given backend. There is one singleton per backend, and like New,
+// it reports data only for self. When using binutils, go test tools do not
+// embed symbols by default, you need to explicitly use `go test -o filename`
+// else this will fail and not be able to get the info. The native backend
+// does not have this limitation. BackendProfile is not an object file
+// backend, use NewSampleResolver for it.
+func Open(backend Backend) (*ObjFile, error) {
 	globalMu.Lock()
 	defer globalMu.Unlock()
 
-	if globalObjFile != nil {
-		return globalObjFile, nil
+	if of, ok := globalObjFiles[backend]; ok {
+		return of, nil
 	}
 
 	argv0, err := findArgv0()
 	if err != nil {
 		return nil, err
 	}
-	f, err := globalBinutils.Open(argv0, 0, ^uint64(0), 0)
-	if err != nil {
-		return nil, err
+
+	var f sourceLiner
+	switch backend {
+	case BackendAuto:
+		// Binutils is only a fallback here, if it's not installed,
+		// native resolution still works, so ignore the error.
+		fb := &fallback{primary: newNative(argv0)}
+		if secondary, err := globalBinutils.Open(argv0, 0, ^uint64(0), 0); err == nil {
+			fb.secondary = &async{objFile: secondary}
+		}
+		f = fb
+	case BackendNative:
+		f = newNative(argv0)
+	case BackendBinutils:
+		bf, err := globalBinutils.Open(argv0, 0, ^uint64(0), 0)
+		if err != nil {
+			return nil, err
+		}
+		f = &async{objFile: bf}
+	default:
+		return nil, UnknownBackendError{Backend: backend}
 	}
-	globalObjFile = &ObjFile{objFile: f}
-	return globalObjFile, nil
+
+	of := &ObjFile{objFile: f, c: newCache(DefaultCacheCapacity)}
+	globalObjFiles[backend] = of
+	return of, nil
+}
+
+// Close releases the resources of an object file returned by OpenBinary,
+// or OpenProcess, typically the addr2line processes it started. It does
+// nothing for the singletons returned by New and Open.
+func (bof *ObjFile) Close() error {
+	if bof == nil || bof.closer == nil {
+		return nil
+	}
+	return bof.closer.Close()
 }
 
 // Name of the binary file.
@@ -68,67 +151,96 @@ func (bof *ObjFile) Name() string {
 	return bof.objFile.Name()
 }
 
+// ResolveSample returns the leaf source line for a profile sample.
+// Only the addresses of the sample are used, the symbols which might
+// be in the profile are ignored.
+func (bof *ObjFile) ResolveSample(ctx context.Context, filter *Filter, sample *profile.Sample) (*Location, error) {
+	if sample == nil {
+		return nil, NoAddrError{}
+	}
+	addrs := make([]uint64, 0, len(sample.Location))
+	for _, loc := range sample.Location {
+		addrs = append(addrs, loc.Address)
+	}
+	return bof.ResolveContext(ctx, filter, addrs)
+}
+
 // Resolve returns the leaf source line for a location.
+// The leaf is found with NewFilter(contains), the location
+// is aggregated with the default Key, so it has no line.
 func (bof *ObjFile) Resolve(contains string, addrs []uint64) (*Location, error) {
+	loc, err := bof.ResolveContext(context.Background(), NewFilter(contains), addrs)
+	if err != nil {
+		return nil, err
+	}
+	ret := Key{}.Apply(*loc)
+	return &ret, nil
+}
+
+// ResolveContext returns the leaf source line for a location, with all
+// its fields, use Key.Apply to aggregate it. It returns the context error
+// if ctx is done before it is found.
+func (bof *ObjFile) ResolveContext(ctx context.Context, filter *Filter, addrs []uint64) (*Location, error) {
 	if bof == nil {
 		return nil, NilObjFileError{}
 	}
 	if len(addrs) < 1 {
 		return nil, NoAddrError{}
 	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 
-	// return data from cache if available
-	if cached := bof.c.get(addrs); cached != nil {
+	// return data from cache if available, the filter is part of
+	// the key, as it changes which frame is the leaf
+	key := cacheKey(filter.key(), addrs)
+	if cached := bof.c.get(key); cached != nil {
 		return cached, nil
 	}
 
-	var leaf int
-	for i, addr := range addrs {
-		frames, err := bof.objFile.SourceLine(addr)
+	frames := make([]plugin.Frame, 0, len(addrs))
+	for _, addr := range addrs {
+		f, err := bof.objFile.SourceLine(ctx, addr)
 		if err != nil {
 			return nil, err
 		}
-		if len(frames) < 1 {
-			return nil, NoFrame0Error{}
-		}
-		file := frames[0].File
-		if strings.Contains(file, contains) && !strings.Contains(file, "/vendor/") {
-			// If the file contains what we're searching for, consider we found leaf.
-			// Excluding paths containing vendor because my-package/vendor/github.com/other
-			// is probably not our code, so not a really interesting leaf.
-			leaf = i
-			break
+		if len(f) < 1 {
+			// Not Go code, or not in any mapping, just ignore it.
+			continue
 		}
+		// Only keep the first frame, inlined callers are ignored.
+		frames = append(frames, f[0])
 	}
-
-	n := len(addrs) - leaf
-	funcs := make([]string, 0, n)
-	// Starting at len(addrs)-2, len(addrs)-1 is usually runtime.goexit, not interesting
-	i0 := len(addrs) - 2
-	if i0 < 0 {
-		i0 = 0
+	if len(frames) < 1 {
+		return nil, NoFrame0Error{}
 	}
 
-	loc := Location{}
-	for i := i0; i >= leaf; i-- {
-		frames, err := bof.objFile.SourceLine(addrs[i])
-		if err != nil {
-			return nil, err
-		}
-		if len(frames) < 1 {
-			return nil, NoFrame0Error{}
-		}
-		funcs = append(funcs, funcOnly(frames[0].Func))
-		if i == leaf {
-			loc.Function = frames[0].Func
-			loc.File = frames[0].File
-		}
-	}
-
-	loc.Stack = strings.Join(funcs, "/")
+	loc := locate(filter, frames)
 
 	// set data in cache for later use
-	bof.c.set(addrs, &loc)
+	bof.c.set(key, &loc)
 
 	return &loc, nil
 }
+
+// CacheStats returns counters about the location cache.
+func (bof *ObjFile) CacheStats() CacheStats {
+	if bof == nil {
+		return CacheStats{}
+	}
+	return bof.c.stats()
+}
+
+// SetCacheCapacity sets the maximum number of locations kept in cache,
+// the least recently used ones are evicted first. Default is
+// DefaultCacheCapacity. As object files are singletons, this is global.
+func (bof *ObjFile) SetCacheCapacity(capacity int) error {
+	if bof == nil {
+		return NilObjFileError{}
+	}
+	if capacity <= 0 {
+		return InvalidCacheCapacityError{Capacity: capacity}
+	}
+	bof.c.setCapacity(capacity)
+	return nil
+}
@@ -6,6 +6,8 @@
 package objfile
 
 import (
+	"context"
+	"runtime"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -24,3 +26,45 @@ func TestObjFile(t *testing.T) {
 	assert.NotNil(err, "this fails, but does not crash")
 	assert.Nil(l)
 }
+
+func TestOpenNative(t *testing.T) {
+	assert := assert.New(t)
+
+	of, err := Open(BackendNative)
+	assert.Nil(err)
+	assert.NotNil(of)
+
+	pcs := make([]uintptr, 32)
+	n := runtime.Callers(1, pcs)
+	assert.True(n > 0)
+	addrs := make([]uint64, 0, n)
+	for _, pc := range pcs[:n] {
+		addrs = append(addrs, uint64(pc))
+	}
+
+	l, err := of.Resolve("livepprof", addrs)
+	assert.Nil(err)
+	assert.NotNil(l)
+	assert.Contains(l.Function, "TestOpenNative")
+	assert.Contains(l.File, "objfile_test.go")
+	t.Logf("%s", l.String())
+
+	hits := of.CacheStats().Hits
+	_, err = of.Resolve("livepprof", addrs)
+	assert.Nil(err)
+	assert.Equal(hits+1, of.CacheStats().Hits)
+	assert.Equal(InvalidCacheCapacityError{Capacity: 0}, of.SetCacheCapacity(0))
+	assert.Equal(DefaultCacheCapacity, of.CacheStats().Capacity)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	var r Resolver = of
+	cr, ok := r.(ContextResolver)
+	if assert.True(ok) {
+		_, err = cr.ResolveContext(ctx, NewFilter("livepprof"), addrs)
+		assert.Equal(context.Canceled, err)
+	}
+
+	_, err = Open(Backend(42))
+	assert.Equal(UnknownBackendError{Backend: Backend(42)}, err)
+}
@@ -0,0 +1,61 @@
+// Live pprof is a Golang library to generate and use live profiles.
+// Copyright (C)  2018  Christian Mauduit <ufoot@ufoot.org>
+// Live pprof homepage: https://github.com/ufoot/livepprof
+// Contact author: ufoot@ufoot.org
+
+package objfile
+
+import (
+	"context"
+
+	"github.com/google/pprof/profile"
+
+	"github.com/ufoot/livepprof/internal/google/plugin"
+)
+
+// ProfileResolver builds locations from the symbols which are already
+// in the profile. Go runtime/pprof fills them, including inlined functions,
+// so there's no need to open the binary and resolve addresses again.
+type ProfileResolver struct{}
+
+var _ SampleResolver = &ProfileResolver{}
+
+// NewProfileResolver returns a resolver using symbols from the profile.
+func NewProfileResolver() *ProfileResolver {
+	return &ProfileResolver{}
+}
+
+// ResolveSample returns the leaf source line for a profile sample.
+// Inlined functions are considered as any other caller. Locations
+// without any symbol (typically, non-Go code) are ignored.
+func (pr *ProfileResolver) ResolveSample(ctx context.Context, filter *Filter, sample *profile.Sample) (*Location, error) {
+	if sample == nil || len(sample.Location) < 1 {
+		return nil, NoAddrError{}
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	frames := make([]plugin.Frame, 0, len(sample.Location))
+	for _, loc := range sample.Location {
+		// Lines are ordered like frames, the inlined functions first,
+		// and the function they have been inlined in last.
+		for _, line := range loc.Line {
+			if line.Function == nil {
+				continue
+			}
+			frames = append(frames, plugin.Frame{
+				Func: line.Function.Name,
+				File: line.Function.Filename,
+				Line: int(line.Line),
+			})
+		}
+	}
+	if len(frames) < 1 {
+		return nil, NoFrame0Error{}
+	}
+
+	loc := locate(filter, frames)
+
+	return &loc, nil
+}
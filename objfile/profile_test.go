@@ -0,0 +1,77 @@
+// Live pprof is a Golang library to generate and use live profiles.
+// Copyright (C)  2018  Christian Mauduit <ufoot@ufoot.org>
+// Live pprof homepage: https://github.com/ufoot/livepprof
+// Contact author: ufoot@ufoot.org
+
+package objfile
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/pprof/profile"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProfileResolver(t *testing.T) {
+	assert := assert.New(t)
+
+	leaf := &profile.Function{Name: "strings.Index", Filename: "/go/src/strings/strings.go"}
+	inlined := &profile.Function{Name: "github.com/me/mypackage.inlined", Filename: "/src/github.com/me/mypackage/a.go"}
+	caller := &profile.Function{Name: "github.com/me/mypackage.caller", Filename: "/src/github.com/me/mypackage/b.go"}
+	main := &profile.Function{Name: "main.main", Filename: "/src/github.com/me/mycommand/main.go"}
+	goexit := &profile.Function{Name: "runtime.goexit", Filename: "/go/src/runtime/asm_amd64.s"}
+
+	sample := &profile.Sample{
+		Location: []*profile.Location{
+			{Line: []profile.Line{{Function: leaf, Line: 10}}},
+			{Line: []profile.Line{{Function: inlined, Line: 20}, {Function: caller, Line: 30}}},
+			{},
+			{Line: []profile.Line{{Function: main, Line: 40}}},
+			{Line: []profile.Line{{Function: goexit, Line: 50}}},
+		},
+	}
+
+	mainFrame := Frame{Package: "main", Function: "main.main", File: "/src/github.com/me/mycommand/main.go"}
+	callerFrame := Frame{Package: "github.com/me/mypackage", Function: "github.com/me/mypackage.caller", File: "/src/github.com/me/mypackage/b.go"}
+	inlinedFrame := Frame{Package: "github.com/me/mypackage", Function: "github.com/me/mypackage.inlined", File: "/src/github.com/me/mypackage/a.go"}
+	leafFrame := Frame{Package: "strings", Function: "strings.Index", File: "/go/src/strings/strings.go"}
+
+	pr := NewProfileResolver()
+
+	l, err := pr.ResolveSample(context.Background(), NewFilter("mypackage"), sample)
+	assert.Nil(err)
+	assert.Equal(Location{
+		Function: "github.com/me/mypackage.inlined",
+		File:     "/src/github.com/me/mypackage/a.go",
+		Stack:    "main.main/mypackage.caller/mypackage.inlined",
+		Frames:   NewFrames([]Frame{mainFrame, callerFrame, inlinedFrame}),
+		Line:     20,
+	}, *l)
+
+	l, err = pr.ResolveSample(context.Background(), NewFilter("nothing"), sample)
+	assert.Nil(err)
+	assert.Equal(Location{
+		Function: "strings.Index",
+		File:     "/go/src/strings/strings.go",
+		Stack:    "main.main/mypackage.caller/mypackage.inlined/strings.Index",
+		Frames:   NewFrames([]Frame{mainFrame, callerFrame, inlinedFrame, leafFrame}),
+		Line:     10,
+	}, *l)
+
+	sample.Location = sample.Location[:4]
+	l, err = pr.ResolveSample(context.Background(), NewFilter("mycommand"), sample)
+	assert.Nil(err)
+	assert.Equal(Location{
+		Function: "main.main",
+		File:     "/src/github.com/me/mycommand/main.go",
+		Stack:    "main.main",
+		Frames:   NewFrames([]Frame{mainFrame}),
+		Line:     40,
+	}, *l)
+
+	_, err = pr.ResolveSample(context.Background(), NewFilter("mypackage"), &profile.Sample{Location: []*profile.Location{{}}})
+	assert.Equal(NoFrame0Error{}, err)
+	_, err = pr.ResolveSample(context.Background(), NewFilter("mypackage"), &profile.Sample{})
+	assert.Equal(NoAddrError{}, err)
+}
@@ -6,9 +6,14 @@
 package livepprof
 
 import (
+	"context"
 	"fmt"
 	"math/rand"
 	"time"
+
+	"github.com/ufoot/livepprof/collector"
+	"github.com/ufoot/livepprof/collector/heap"
+	"github.com/ufoot/livepprof/objfile"
 )
 
 const (
@@ -19,22 +24,39 @@ const (
 	// defaultLimit to not keep every single item in memory, only the
 	// ones with the biggest numbers are kept (sorted before filtering out).
 	defaultLimit = 20
+	// defaultSinkBuffer is the number of Data a sink can lag behind.
+	defaultSinkBuffer = 16
 )
 
 type opts struct {
-	filter      string
-	errHandler  func(err error)
-	delay       time.Duration
-	jitter      float64
-	limit       int
-	disabled    bool
-	enabledFunc func() bool
+	filter        string
+	errHandler    func(err error)
+	delay         time.Duration
+	jitter        float64
+	limit         int
+	disabled      bool
+	enabledFunc   func() bool
+	backend       objfile.Backend
+	mutexFraction int
+	blockRate     int
+	heapSample    string
+	sinks         []Sink
+	sinkBuffer    int
+	archiver      Archiver
+	ctx           context.Context
+	timeout       time.Duration
+	busyPolicy    collector.BusyPolicy
+	leafFilter    *objfile.Filter
+	key           objfile.Key
+	cacheCapacity int
 }
 
 var defaultOpts = opts{
-	delay:  defaultDelay,
-	jitter: defaultJitter,
-	limit:  defaultLimit,
+	delay:      defaultDelay,
+	jitter:     defaultJitter,
+	limit:      defaultLimit,
+	sinkBuffer: defaultSinkBuffer,
+	ctx:        context.Background(),
 }
 
 func (o *opts) enabled() bool {
@@ -53,6 +75,15 @@ func (o *opts) jitteredDelay(r *rand.Rand) time.Duration {
 	return time.Duration(float64(o.delay) * (1.0 + (o.jitter * (r.Float64() - 0.5))))
 }
 
+// collectTimeout is the deadline for a single collection, by default twice
+// the delay, as CPU, mutex and block collections last for the delay itself.
+func (o *opts) collectTimeout() time.Duration {
+	if o.timeout == 0 {
+		return 2 * o.delay
+	}
+	return o.timeout
+}
+
 // Option passed when creating the live profiler.
 type Option func(o *opts) error
 
@@ -73,6 +104,36 @@ func WithFilter(filter string) Option {
 	}
 }
 
+// WithLeafFilter is like WithFilter, but with several include patterns,
+// exclude patterns, regexps, and matching on function or package names
+// instead of file paths. When set, the WithFilter string is ignored.
+// Note that objfile.VendorPattern is not excluded unless it is in the
+// filter, see objfile.NewFilter for what WithFilter does.
+func WithLeafFilter(filter *objfile.Filter) Option {
+	return func(o *opts) error {
+		if filter == nil {
+			return fmt.Errorf("nil leaf filter")
+		}
+		o.leafFilter = filter
+		return nil
+	}
+}
+
+// WithMainModuleFilter aggregates data on the code of the main module,
+// and of modules replaced by local directories, so that there is no need
+// to guess a path substring, see objfile.MainModuleFilter. It fails if
+// the program was not built with modules.
+func WithMainModuleFilter() Option {
+	return func(o *opts) error {
+		filter, err := objfile.MainModuleFilter()
+		if err != nil {
+			return err
+		}
+		o.leafFilter = filter
+		return nil
+	}
+}
+
 // WithErrorHandler allows custom handling of errors.
 // This is useful as live profiler does thing in the background, the
 // instanciation and start can not return all possible errors, so
@@ -146,3 +207,178 @@ func WithEnabledFunc(enabledFunc func() bool) Option {
 		return nil
 	}
 }
+
+// WithBackend allows you to choose how addresses are resolved to locations.
+// Default is objfile.BackendAuto, which is pure Go and falls back on
+// GNU binutils if needed. Use objfile.BackendBinutils to get the
+// historical behavior, which requires binutils to be installed, or
+// objfile.BackendProfile to use the symbols already in the profile.
+func WithBackend(backend objfile.Backend) Option {
+	return func(o *opts) error {
+		switch backend {
+		case objfile.BackendAuto, objfile.BackendNative, objfile.BackendBinutils, objfile.BackendProfile:
+		default:
+			return fmt.Errorf("invalid backend: %s", backend.String())
+		}
+		o.backend = backend
+		return nil
+	}
+}
+
+// WithMutexFraction enables mutex contention profiles, see Mutex. On average,
+// 1 contention event out of fraction is reported, 100 is a good start. It is only
+// set while collecting data, see runtime.SetMutexProfileFraction. Default is 0,
+// mutex contention is not profiled.
+func WithMutexFraction(fraction int) Option {
+	return func(o *opts) error {
+		if fraction < 0 {
+			return fmt.Errorf("invalid mutex fraction: %d", fraction)
+		}
+		o.mutexFraction = fraction
+		return nil
+	}
+}
+
+// WithBlockRate enables blocking profiles, see Block. On average, 1 blocking
+// event per rate nanoseconds spent blocked is reported, 10000 is a good start.
+// The rate is set once, when the first profile is collected, and never reset,
+// see runtime.SetBlockProfileRate. This is a permanent, process-wide side
+// effect: the rate stays after Close, and every blocking event of the program
+// keeps paying the profiling cost. The previous rate is not restored, as the
+// runtime offers no way to read it. If the program sets the rate itself, pass
+// a negative rate, blocking profiles are enabled, but the rate is untouched.
+// Default is 0, blocking is not profiled.
+func WithBlockRate(rate int) Option {
+	return func(o *opts) error {
+		o.blockRate = rate
+		return nil
+	}
+}
+
+// WithHeapSampleType allows you to choose what heap data is reported.
+// Default is heap.SampleTypeInuseSpace, the bytes in use. Other possible
+// values are heap.SampleTypeInuseObjects, heap.SampleTypeAllocSpace and
+// heap.SampleTypeAllocObjects. Allocations are reported per second,
+// computed from the previous heartbeat, see heap.New.
+func WithHeapSampleType(sampleType string) Option {
+	return func(o *opts) error {
+		switch sampleType {
+		case heap.SampleTypeInuseSpace, heap.SampleTypeInuseObjects,
+			heap.SampleTypeAllocSpace, heap.SampleTypeAllocObjects:
+		default:
+			return fmt.Errorf("invalid heap sample type: %s", sampleType)
+		}
+		o.heapSample = sampleType
+		return nil
+	}
+}
+
+// WithSink adds a sink, which receives all data as it is collected.
+// It can be used several times, to send data to several sinks.
+// Sinks never block collection, if one is too slow, data is dropped
+// and reported to the error handler. When there are sinks, channels
+// are optional, data is only sent on them if someone is reading.
+func WithSink(sink Sink) Option {
+	return func(o *opts) error {
+		if sink == nil {
+			return fmt.Errorf("invalid nil sink")
+		}
+		// Copying, as defaultOpts would share the same array otherwise.
+		o.sinks = append(append([]Sink(nil), o.sinks...), sink)
+		return nil
+	}
+}
+
+// WithSinkBuffer allows a custom sink buffer size to be used. Default is 16.
+// This is the number of Data a sink can lag behind before data is dropped.
+func WithSinkBuffer(size int) Option {
+	return func(o *opts) error {
+		if size <= 0 {
+			return fmt.Errorf("invalid sink buffer: %d", size)
+		}
+		o.sinkBuffer = size
+		return nil
+	}
+}
+
+// WithArchive stores the raw profiles data is computed from, typically
+// with archive.New. They have the same timestamp as the Data sent,
+// so that, if something looks odd, the full profile can be inspected.
+// Profiles are collected for the archive only if it archives their kind,
+// see KindArchiver.
+func WithArchive(archiver Archiver) Option {
+	return func(o *opts) error {
+		o.archiver = archiver
+		return nil
+	}
+}
+
+// WithContext sets a parent context for the profiler. When it is done,
+// collections in progress are cancelled, and no new data is sent,
+// as if the profiler was stopped. Default is context.Background().
+func WithContext(ctx context.Context) Option {
+	return func(o *opts) error {
+		if ctx == nil {
+			return fmt.Errorf("nil context")
+		}
+		o.ctx = ctx
+		return nil
+	}
+}
+
+// WithTimeout sets a deadline for each collection, including the time
+// needed to resolve addresses. Collections which take longer are cancelled
+// and reported to the error handler. Default is twice the delay.
+func WithTimeout(timeout time.Duration) Option {
+	return func(o *opts) error {
+		if timeout <= 0 {
+			return fmt.Errorf("invalid timeout: %s", timeout.String())
+		}
+		o.timeout = timeout
+		return nil
+	}
+}
+
+// WithBusyPolicy sets what to do when the CPU profiler is used by someone
+// else, typically net/http/pprof. With collector.BusySkip, the default,
+// the window is lost and a cpu.BusyError is reported. With
+// collector.BusyDefer, the window starts once the profiler is free.
+// To share windows with /debug/pprof/profile instead, serve cpu.Handler.
+func WithBusyPolicy(policy collector.BusyPolicy) Option {
+	return func(o *opts) error {
+		switch policy {
+		case collector.BusySkip, collector.BusyDefer:
+		default:
+			return fmt.Errorf("unknown busy policy: %d", policy)
+		}
+		o.busyPolicy = policy
+		return nil
+	}
+}
+
+// WithKey sets how data is aggregated, for instance by function only,
+// by line, or by package, to tune the number of different entries.
+// Default is the function and the full stack, see objfile.Key.
+func WithKey(key objfile.Key) Option {
+	return func(o *opts) error {
+		if !key.Valid() {
+			return fmt.Errorf("invalid key: %s, callers: %d", key.Granularity.String(), key.Callers)
+		}
+		o.key = key
+		return nil
+	}
+}
+
+// WithCacheCapacity sets the maximum number of locations kept in cache,
+// to avoid resolving the same addresses over and over. Default is
+// objfile.DefaultCacheCapacity. Object files are shared by all profilers
+// using the same backend, so this is global.
+func WithCacheCapacity(capacity int) Option {
+	return func(o *opts) error {
+		if capacity <= 0 {
+			return objfile.InvalidCacheCapacityError{Capacity: capacity}
+		}
+		o.cacheCapacity = capacity
+		return nil
+	}
+}
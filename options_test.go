@@ -6,12 +6,18 @@
 package livepprof
 
 import (
+	"context"
 	"math"
 	"math/rand"
+	"runtime/debug"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+
+	"github.com/ufoot/livepprof/collector"
+	"github.com/ufoot/livepprof/collector/heap"
+	"github.com/ufoot/livepprof/objfile"
 )
 
 func TestOptsEnabled(t *testing.T) {
@@ -149,3 +155,163 @@ func TestWithEnabledFunc(t *testing.T) {
 	o.disabled = true
 	assert.True(o.enabled())
 }
+
+func TestWithBackend(t *testing.T) {
+	assert := assert.New(t)
+
+	o := defaultOpts
+	assert.Equal(objfile.BackendAuto, o.backend)
+	assert.Nil(WithBackend(objfile.BackendNative)(&o))
+	assert.Equal(objfile.BackendNative, o.backend)
+	assert.Nil(WithBackend(objfile.BackendBinutils)(&o))
+	assert.Equal(objfile.BackendBinutils, o.backend)
+	assert.Nil(WithBackend(objfile.BackendProfile)(&o))
+	assert.Equal(objfile.BackendProfile, o.backend)
+	assert.NotNil(WithBackend(objfile.Backend(42))(&o))
+	assert.Equal(objfile.BackendProfile, o.backend)
+}
+
+func TestWithMutexFraction(t *testing.T) {
+	assert := assert.New(t)
+
+	o := defaultOpts
+	assert.Equal(0, o.mutexFraction)
+	assert.Nil(WithMutexFraction(10)(&o))
+	assert.Equal(10, o.mutexFraction)
+	assert.Nil(WithMutexFraction(0)(&o))
+	assert.Equal(0, o.mutexFraction)
+	assert.NotNil(WithMutexFraction(-1)(&o))
+	assert.Equal(0, o.mutexFraction)
+}
+
+func TestWithBlockRate(t *testing.T) {
+	assert := assert.New(t)
+
+	o := defaultOpts
+	assert.Equal(0, o.blockRate)
+	assert.Nil(WithBlockRate(1)(&o))
+	assert.Equal(1, o.blockRate)
+	assert.Nil(WithBlockRate(-1)(&o))
+	assert.Equal(-1, o.blockRate)
+}
+
+func TestWithHeapSampleType(t *testing.T) {
+	assert := assert.New(t)
+
+	o := defaultOpts
+	assert.Equal("", o.heapSample)
+	assert.Nil(WithHeapSampleType(heap.SampleTypeAllocSpace)(&o))
+	assert.Equal("alloc_space", o.heapSample)
+	assert.Nil(WithHeapSampleType(heap.SampleTypeInuseObjects)(&o))
+	assert.Equal("inuse_objects", o.heapSample)
+	assert.NotNil(WithHeapSampleType("nothing")(&o))
+	assert.Equal("inuse_objects", o.heapSample)
+}
+
+func TestWithSink(t *testing.T) {
+	assert := assert.New(t)
+
+	o := defaultOpts
+	assert.Equal(0, len(o.sinks))
+	assert.Nil(WithSink(&testSink{})(&o))
+	assert.Equal(1, len(o.sinks))
+	assert.Nil(WithSink(&testSink{})(&o))
+	assert.Equal(2, len(o.sinks))
+	assert.NotNil(WithSink(nil)(&o))
+	assert.Equal(2, len(o.sinks))
+	assert.Equal(0, len(defaultOpts.sinks))
+}
+
+func TestWithSinkBuffer(t *testing.T) {
+	assert := assert.New(t)
+
+	o := defaultOpts
+	assert.Equal(16, o.sinkBuffer)
+	assert.Nil(WithSinkBuffer(100)(&o))
+	assert.Equal(100, o.sinkBuffer)
+	assert.NotNil(WithSinkBuffer(0)(&o))
+	assert.Equal(100, o.sinkBuffer)
+}
+
+func TestWithContext(t *testing.T) {
+	assert := assert.New(t)
+
+	o := defaultOpts
+	assert.Equal(context.Background(), o.ctx)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	assert.Nil(WithContext(ctx)(&o))
+	assert.Equal(ctx, o.ctx)
+	assert.NotNil(WithContext(nil)(&o))
+	assert.Equal(ctx, o.ctx)
+}
+
+func TestWithTimeout(t *testing.T) {
+	assert := assert.New(t)
+
+	o := defaultOpts
+	assert.Equal(2*time.Minute, o.collectTimeout())
+	assert.Nil(WithTimeout(time.Second)(&o))
+	assert.Equal(time.Second, o.collectTimeout())
+	assert.NotNil(WithTimeout(0)(&o))
+	assert.Equal(time.Second, o.collectTimeout())
+}
+
+func TestWithBusyPolicy(t *testing.T) {
+	assert := assert.New(t)
+
+	o := defaultOpts
+	assert.Equal(collector.BusySkip, o.busyPolicy)
+	assert.Nil(WithBusyPolicy(collector.BusyDefer)(&o))
+	assert.Equal(collector.BusyDefer, o.busyPolicy)
+	assert.NotNil(WithBusyPolicy(collector.BusyPolicy(42))(&o))
+	assert.Equal(collector.BusyDefer, o.busyPolicy)
+}
+
+func TestWithLeafFilter(t *testing.T) {
+	assert := assert.New(t)
+
+	o := defaultOpts
+	assert.Nil(o.leafFilter)
+	f := objfile.NewFilter("livepprof")
+	assert.Nil(WithLeafFilter(f)(&o))
+	assert.Equal(f, o.leafFilter)
+	assert.NotNil(WithLeafFilter(nil)(&o))
+	assert.Equal(f, o.leafFilter)
+}
+
+func TestWithMainModuleFilter(t *testing.T) {
+	assert := assert.New(t)
+
+	if info, ok := debug.ReadBuildInfo(); !ok || info.Main.Path == "" {
+		t.Skip("no build info, binary not built with modules")
+	}
+
+	o := defaultOpts
+	assert.Nil(WithMainModuleFilter()(&o))
+	assert.NotNil(o.leafFilter)
+	assert.True(o.leafFilter.Match("github.com/ufoot/livepprof.allocator1", "livepprof_test.go"))
+}
+
+func TestWithKey(t *testing.T) {
+	assert := assert.New(t)
+
+	o := defaultOpts
+	assert.Equal(objfile.Key{}, o.key)
+	key := objfile.Key{Granularity: objfile.GranularityCallers, Callers: 2}
+	assert.Nil(WithKey(key)(&o))
+	assert.Equal(key, o.key)
+	assert.NotNil(WithKey(objfile.Key{Granularity: objfile.Granularity(42)})(&o))
+	assert.Equal(key, o.key)
+}
+
+func TestWithCacheCapacity(t *testing.T) {
+	assert := assert.New(t)
+
+	o := defaultOpts
+	assert.Equal(0, o.cacheCapacity)
+	assert.Nil(WithCacheCapacity(1000)(&o))
+	assert.Equal(1000, o.cacheCapacity)
+	assert.NotNil(WithCacheCapacity(0)(&o))
+	assert.Equal(1000, o.cacheCapacity)
+}
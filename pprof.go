@@ -0,0 +1,214 @@
+// Live pprof is a Golang library to generate and use live profiles.
+// Copyright (C)  2018  Christian Mauduit <ufoot@ufoot.org>
+// Live pprof homepage: https://github.com/ufoot/livepprof
+// Contact author: ufoot@ufoot.org
+
+package livepprof
+
+import (
+	"math"
+	"time"
+
+	"github.com/google/pprof/profile"
+
+	"github.com/ufoot/livepprof/collector/block"
+	"github.com/ufoot/livepprof/collector/heap"
+	"github.com/ufoot/livepprof/collector/mutex"
+	"github.com/ufoot/livepprof/objfile"
+)
+
+// defaultSampleType returns the sample type the profiler reports
+// for a kind of data, when it is not configured.
+func defaultSampleType(kind string) string {
+	switch kind {
+	case KindCPU:
+		return "samples"
+	case KindHeap:
+		return heap.SampleTypeInuseSpace
+	case KindGoroutine:
+		return "goroutine"
+	case KindMutex:
+		return mutex.SampleTypeDelay
+	case KindBlock:
+		return block.SampleTypeDelay
+	}
+	return kind
+}
+
+// ValueType returns the pprof sample type for a kind of data, which values
+// come from sampleType, as in Data.SampleType. If it is empty, the sample type
+// used by default by the profiler is assumed. CPU samples are reported as
+// CPU time, in nanoseconds. Unknown types are reported as a plain count.
+func ValueType(kind, sampleType string) *profile.ValueType {
+	if sampleType == "" {
+		sampleType = defaultSampleType(kind)
+	}
+	switch sampleType {
+	case heap.SampleTypeInuseSpace, heap.SampleTypeAllocSpace:
+		return &profile.ValueType{Type: sampleType, Unit: "bytes"}
+	case mutex.SampleTypeDelay:
+		return &profile.ValueType{Type: sampleType, Unit: "nanoseconds"}
+	case "samples", "cpu":
+		return &profile.ValueType{Type: "cpu", Unit: "nanoseconds"}
+	}
+	return &profile.ValueType{Type: sampleType, Unit: "count"}
+}
+
+// rateFactor returns what rates per second of a sample type are multiplied
+// by, to get the quantity of ValueType during one second. It returns 0 for
+// sample types which are not rates, such as the bytes in use.
+func rateFactor(sampleType string) float64 {
+	switch sampleType {
+	case "samples":
+		return float64(time.Second) / cpuHz
+	case "cpu", heap.SampleTypeAllocSpace, heap.SampleTypeAllocObjects,
+		mutex.SampleTypeContentions, mutex.SampleTypeDelay:
+		return 1
+	}
+	return 0
+}
+
+// profileBuilder synthesizes functions and locations from stacks.
+type profileBuilder struct {
+	p         *profile.Profile
+	functions map[funcKey]*profile.Function
+	locations map[locKey]*profile.Location
+	samples   map[objfile.Location]*profile.Sample
+}
+
+type funcKey struct {
+	name string
+	file string
+}
+
+type locKey struct {
+	fn   *profile.Function
+	line int
+}
+
+func (pb *profileBuilder) location(name, file string, line int) *profile.Location {
+	key := funcKey{name: name, file: file}
+	fn, ok := pb.functions[key]
+	if !ok {
+		fn = &profile.Function{
+			ID:         uint64(len(pb.p.Function) + 1),
+			Name:       name,
+			SystemName: name,
+			Filename:   file,
+		}
+		pb.functions[key] = fn
+		pb.p.Function = append(pb.p.Function, fn)
+	}
+	lk := locKey{fn: fn, line: line}
+	loc, ok := pb.locations[lk]
+	if !ok {
+		loc = &profile.Location{
+			ID:   uint64(len(pb.p.Location) + 1),
+			Line: []profile.Line{{Function: fn, Line: int64(line)}},
+		}
+		pb.locations[lk] = loc
+		pb.p.Location = append(pb.p.Location, loc)
+	}
+	return loc
+}
+
+func (pb *profileBuilder) sample(key objfile.Location) *profile.Sample {
+	if s, ok := pb.samples[key]; ok {
+		return s
+	}
+
+	// Frames have complete names and files, data built by older
+	// versions only has short names in the legacy format.
+	frames := key.Frames.Slice()
+	if frames == nil {
+		frames = objfile.ParseStack(key.Stack).Slice()
+	}
+	// The leaf is the last one in the stack, use the one from
+	// the key, which is there even without a stack, with the line.
+	if len(frames) > 0 {
+		frames = frames[:len(frames)-1]
+	}
+
+	// Profile locations start with the leaf, and end with callers.
+	s := &profile.Sample{
+		Location: make([]*profile.Location, 0, len(frames)+1),
+		Value:    []int64{0},
+	}
+	s.Location = append(s.Location, pb.location(key.Function, key.File, key.Line))
+	for i := len(frames) - 1; i >= 0; i-- {
+		s.Location = append(s.Location, pb.location(frames[i].Function, frames[i].File, 0))
+	}
+	pb.samples[key] = s
+	pb.p.Sample = append(pb.p.Sample, s)
+	return s
+}
+
+// ToProfile converts data to a pprof profile, so that it can be used with
+// standard tools such as `go tool pprof`. If several data are given, typically
+// a time window, values are averaged. Rates, such as CPU or allocations per
+// second, are multiplied by the duration of the window, from the first data
+// to the last one, or one second for a single data, so that the profile has
+// the quantities for the whole window, as pprof expects, and small rates are
+// not rounded to zero. Functions and locations are synthesized from the
+// Frames of each entry, each with its complete name and file. Data without
+// frames, built by older versions, only has the short names of the Stack,
+// then only the leaf has a file name. There are no addresses, as they are
+// not kept in Data, and only the leaf has a line number, with
+// objfile.GranularityLine, see WithKey. The sample type is the one of the
+// first data which has one, see ValueType.
+func ToProfile(kind string, data ...Data) (*profile.Profile, error) {
+	var sampleType string
+	for _, d := range data {
+		if d.SampleType != "" {
+			sampleType = d.SampleType
+			break
+		}
+	}
+	if sampleType == "" {
+		sampleType = defaultSampleType(kind)
+	}
+	vt := ValueType(kind, sampleType)
+	pb := profileBuilder{
+		p: &profile.Profile{
+			SampleType: []*profile.ValueType{vt},
+			PeriodType: &profile.ValueType{Type: vt.Type, Unit: vt.Unit},
+			Period:     1,
+		},
+		functions: make(map[funcKey]*profile.Function),
+		locations: make(map[locKey]*profile.Location),
+		samples:   make(map[objfile.Location]*profile.Sample),
+	}
+
+	var first, last time.Time
+	sums := make(map[*profile.Sample]float64)
+	for i, d := range data {
+		if i == 0 || d.Timestamp.Before(first) {
+			first = d.Timestamp
+		}
+		if i == 0 || d.Timestamp.After(last) {
+			last = d.Timestamp
+		}
+		for _, entry := range d.Entries {
+			sums[pb.sample(entry.Key)] += entry.Value
+		}
+	}
+	if len(data) > 0 {
+		pb.p.TimeNanos = first.UnixNano()
+		pb.p.DurationNanos = last.Sub(first).Nanoseconds()
+		if pb.p.DurationNanos <= 0 {
+			pb.p.DurationNanos = time.Second.Nanoseconds()
+		}
+	}
+	factor := 1.0
+	if f := rateFactor(sampleType); f > 0 {
+		factor = f * float64(pb.p.DurationNanos) / float64(time.Second)
+	}
+	for s, sum := range sums {
+		s.Value[0] = int64(math.Round(sum / float64(len(data)) * factor))
+	}
+
+	if err := pb.p.CheckValid(); err != nil {
+		return nil, err
+	}
+	return pb.p, nil
+}
@@ -0,0 +1,120 @@
+// Live pprof is a Golang library to generate and use live profiles.
+// Copyright (C)  2018  Christian Mauduit <ufoot@ufoot.org>
+// Live pprof homepage: https://github.com/ufoot/livepprof
+// Contact author: ufoot@ufoot.org
+
+package livepprof
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/google/pprof/profile"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ufoot/livepprof/collector/heap"
+	"github.com/ufoot/livepprof/objfile"
+)
+
+func TestToProfile(t *testing.T) {
+	assert := assert.New(t)
+
+	key1 := objfile.Location{
+		Function: "github.com/me/mypackage.f1",
+		File:     "/src/github.com/me/mypackage/a.go",
+		Stack:    "main.main/mypackage.caller/mypackage.f1",
+	}
+	key2 := objfile.Location{
+		Function: "github.com/me/mypackage.f2",
+		File:     "/src/github.com/me/mypackage/b.go",
+		Stack:    "main.main/mypackage.caller/mypackage.f2",
+	}
+	ts := time.Now()
+	data1 := Data{Timestamp: ts, Entries: []Entry{{Key: key1, Value: 100}, {Key: key2, Value: 10}}}
+	data2 := Data{Timestamp: ts.Add(time.Minute), Entries: []Entry{{Key: key1, Value: 200}}}
+
+	p, err := ToProfile(KindCPU, data1, data2)
+	assert.Nil(err)
+	assert.Equal("cpu", p.SampleType[0].Type)
+	assert.Equal("nanoseconds", p.SampleType[0].Unit)
+	assert.Equal(ts.UnixNano(), p.TimeNanos)
+	assert.Equal(time.Minute.Nanoseconds(), p.DurationNanos)
+	assert.Equal(2, len(p.Sample))
+	// main.main, mypackage.caller, and both leaves
+	assert.Equal(4, len(p.Function))
+	assert.Equal(4, len(p.Location))
+
+	var buf bytes.Buffer
+	assert.Nil(p.Write(&buf))
+	p2, err := profile.Parse(&buf)
+	assert.Nil(err)
+
+	values := make(map[string]int64)
+	for _, s := range p2.Sample {
+		assert.Equal(3, len(s.Location))
+		leaf := s.Location[0].Line[0].Function
+		assert.NotEqual("", leaf.Filename)
+		assert.Equal("mypackage.caller", s.Location[1].Line[0].Function.Name)
+		assert.Equal("main.main", s.Location[2].Line[0].Function.Name)
+		values[leaf.Name] = s.Value[0]
+	}
+	// Averaged samples per second, at 100 Hz, during a minute.
+	assert.Equal(map[string]int64{
+		"github.com/me/mypackage.f1": 150 * 10e6 * 60,
+		"github.com/me/mypackage.f2": 5 * 10e6 * 60,
+	}, values)
+
+	// With frames, callers have their complete names and files.
+	key1.Frames = objfile.NewFrames([]objfile.Frame{
+		{Package: "main", Function: "main.main", File: "/src/github.com/me/mycommand/main.go"},
+		{Package: "github.com/me/mypackage", Function: "github.com/me/mypackage.caller", File: "/src/github.com/me/mypackage/c.go"},
+		{Package: "github.com/me/mypackage", Function: "github.com/me/mypackage.f1", File: "/src/github.com/me/mypackage/a.go"},
+	})
+	p, err = ToProfile(KindCPU, Data{Timestamp: ts, Entries: []Entry{{Key: key1, Value: 100}}})
+	assert.Nil(err)
+	if assert.Equal(1, len(p.Sample)) && assert.Equal(3, len(p.Sample[0].Location)) {
+		caller := p.Sample[0].Location[1].Line[0].Function
+		assert.Equal("github.com/me/mypackage.caller", caller.Name)
+		assert.Equal("/src/github.com/me/mypackage/c.go", caller.Filename)
+	}
+
+	p, err = ToProfile(KindHeap)
+	assert.Nil(err)
+	assert.Equal("inuse_space", p.SampleType[0].Type)
+	assert.Equal("bytes", p.SampleType[0].Unit)
+	assert.Equal(0, len(p.Sample))
+
+	p, err = ToProfile(KindHeap, Data{Timestamp: ts, SampleType: heap.SampleTypeAllocObjects})
+	assert.Nil(err)
+	assert.Equal("alloc_objects", p.SampleType[0].Type)
+	assert.Equal("count", p.SampleType[0].Unit)
+
+	// Small rates are not rounded to zero.
+	p, err = ToProfile(KindCPU, Data{Timestamp: ts, Entries: []Entry{{Key: key2, Value: 0.01}}})
+	assert.Nil(err)
+	assert.Equal(time.Second.Nanoseconds(), p.DurationNanos)
+	if assert.Equal(1, len(p.Sample)) {
+		assert.Equal(int64(100000), p.Sample[0].Value[0])
+	}
+	p, err = ToProfile(KindHeap,
+		Data{Timestamp: ts, SampleType: heap.SampleTypeAllocSpace, Entries: []Entry{{Key: key2, Value: 0.25}}},
+		Data{Timestamp: ts.Add(time.Minute), SampleType: heap.SampleTypeAllocSpace, Entries: []Entry{{Key: key2, Value: 0.25}}})
+	assert.Nil(err)
+	if assert.Equal(1, len(p.Sample)) {
+		assert.Equal(int64(15), p.Sample[0].Value[0])
+	}
+}
+
+func TestValueType(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(&profile.ValueType{Type: "cpu", Unit: "nanoseconds"}, ValueType(KindCPU, ""))
+	assert.Equal(&profile.ValueType{Type: "inuse_space", Unit: "bytes"}, ValueType(KindHeap, ""))
+	assert.Equal(&profile.ValueType{Type: "alloc_space", Unit: "bytes"}, ValueType(KindHeap, heap.SampleTypeAllocSpace))
+	assert.Equal(&profile.ValueType{Type: "inuse_objects", Unit: "count"}, ValueType(KindHeap, heap.SampleTypeInuseObjects))
+	assert.Equal(&profile.ValueType{Type: "goroutine", Unit: "count"}, ValueType(KindGoroutine, ""))
+	assert.Equal(&profile.ValueType{Type: "delay", Unit: "nanoseconds"}, ValueType(KindMutex, ""))
+	assert.Equal(&profile.ValueType{Type: "contentions", Unit: "count"}, ValueType(KindBlock, "contentions"))
+	assert.Equal(&profile.ValueType{Type: "other", Unit: "count"}, ValueType("other", ""))
+}
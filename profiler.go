@@ -11,6 +11,12 @@ type Profiler interface {
 	CPU() <-chan Data
 	// Heap channel on which heap data is sent.
 	Heap() <-chan Data
+	// Goroutine channel on which goroutine data is sent.
+	Goroutine() <-chan Data
+	// Mutex channel on which mutex contention data is sent.
+	Mutex() <-chan Data
+	// Block channel on which blocking data is sent.
+	Block() <-chan Data
 	// Close the profiler.
 	Close()
 }
@@ -0,0 +1,62 @@
+// Live pprof is a Golang library to generate and use live profiles.
+// Copyright (C)  2018  Christian Mauduit <ufoot@ufoot.org>
+// Live pprof homepage: https://github.com/ufoot/livepprof
+// Contact author: ufoot@ufoot.org
+
+package rollup
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// defaultWindows which are served over HTTP, the longest one is how long data is kept.
+var defaultWindows = []time.Duration{time.Minute, 5 * time.Minute, time.Hour}
+
+type opts struct {
+	windows []time.Duration
+	kinds   map[string]bool
+}
+
+var defaultOpts = opts{
+	windows: defaultWindows,
+}
+
+// retention is how long data is kept, the longest window.
+func (o *opts) retention() time.Duration {
+	return o.windows[len(o.windows)-1]
+}
+
+// Option passed when creating the rollup.
+type Option func(o *opts) error
+
+// WithWindows allows custom windows to be used. Default is 1 minute,
+// 5 minutes and 1 hour. Data is kept as long as the longest window.
+func WithWindows(windows ...time.Duration) Option {
+	return func(o *opts) error {
+		if len(windows) == 0 {
+			return fmt.Errorf("no windows")
+		}
+		for _, window := range windows {
+			if window <= 0 {
+				return fmt.Errorf("invalid window: %s", window.String())
+			}
+		}
+		o.windows = append([]time.Duration(nil), windows...)
+		sort.Slice(o.windows, func(i, j int) bool { return o.windows[i] < o.windows[j] })
+		return nil
+	}
+}
+
+// WithKinds restricts the rollup to some kinds of profiles, typically
+// "cpu" and "heap". Default is to keep all of them.
+func WithKinds(kinds ...string) Option {
+	return func(o *opts) error {
+		o.kinds = make(map[string]bool, len(kinds))
+		for _, kind := range kinds {
+			o.kinds[kind] = true
+		}
+		return nil
+	}
+}
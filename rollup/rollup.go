@@ -0,0 +1,299 @@
+// Live pprof is a Golang library to generate and use live profiles.
+// Copyright (C)  2018  Christian Mauduit <ufoot@ufoot.org>
+// Live pprof homepage: https://github.com/ufoot/livepprof
+// Contact author: ufoot@ufoot.org
+
+package rollup
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ufoot/livepprof"
+	"github.com/ufoot/livepprof/objfile"
+)
+
+// defaultLimit of the number of stats served over HTTP.
+const defaultLimit = 20
+
+// percentile reported along with the sum and the mean.
+const percentile = 0.95
+
+// WindowTooLongError when asking for a window longer than what is kept.
+type WindowTooLongError struct {
+	// Window asked for.
+	Window time.Duration
+	// Retention is how long data is kept.
+	Retention time.Duration
+}
+
+// Error string.
+func (e WindowTooLongError) Error() string {
+	return "window " + e.Window.String() + " is longer than retention " + e.Retention.String()
+}
+
+// InvalidWindowError when asking for a window which is not positive.
+type InvalidWindowError struct {
+	// Window asked for.
+	Window time.Duration
+}
+
+// Error string.
+func (e InvalidWindowError) Error() string {
+	return "invalid window " + e.Window.String()
+}
+
+// Stats of a location over a window.
+type Stats struct {
+	// Key is the aggregation key for data, basically a location in the code.
+	Key objfile.Location
+	// Sum of the values over all the heartbeats of the window.
+	Sum float64
+	// Mean value per heartbeat. Heartbeats in which the location is
+	// not reported count as 0, so it is what the value was on average.
+	Mean float64
+	// P95 is the 95th percentile of the values, heartbeats in which
+	// the location is not reported count as 0.
+	P95 float64
+	// Count is the number of heartbeats in which the location is reported.
+	Count int
+}
+
+// View of a kind of data over a window.
+type View struct {
+	// Kind of data (livepprof.KindCPU, livepprof.KindHeap...).
+	Kind string
+	// Window the data is aggregated over.
+	Window time.Duration
+	// Start and End of the window.
+	Start time.Time
+	End   time.Time
+	// Heartbeats is the number of Data in the window.
+	Heartbeats int
+	// Stats, sorted by order of importance, greater sums at the beginning.
+	Stats []Stats
+}
+
+// Rollup is a sink which keeps data for some time, and aggregates it over
+// rolling windows, 1 minute, 5 minutes and 1 hour by default. This gives
+// "top functions over the last hour" without profiling again nor storing
+// data elsewhere. It can be queried with Query, or over HTTP, in JSON.
+type Rollup struct {
+	opts opts
+	mu   sync.RWMutex
+	data map[string][]livepprof.Data
+	now  func() time.Time
+}
+
+var _ livepprof.Sink = &Rollup{}
+var _ http.Handler = &Rollup{}
+
+// New rollup sink and handler.
+func New(options ...Option) (*Rollup, error) {
+	opts := defaultOpts
+	for _, opt := range options {
+		if err := opt(&opts); err != nil {
+			return nil, err
+		}
+	}
+	return &Rollup{
+		opts: opts,
+		data: make(map[string][]livepprof.Data),
+		now:  time.Now,
+	}, nil
+}
+
+// Windows returns the windows served over HTTP, shortest first.
+func (r *Rollup) Windows() []time.Duration {
+	return append([]time.Duration(nil), r.opts.windows...)
+}
+
+// Kinds returns the kinds of data there is, in alphabetical order.
+func (r *Rollup) Kinds() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	kinds := make([]string, 0, len(r.data))
+	for kind := range r.data {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	return kinds
+}
+
+// Write stores data, and forgets data older than the longest window.
+func (r *Rollup) Write(kind string, data livepprof.Data) error {
+	if r.opts.kinds != nil && !r.opts.kinds[kind] {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	history := append(r.data[kind], data)
+	// Data is usually received in order, but sinks may be late.
+	sort.SliceStable(history, func(i, j int) bool { return history[i].Timestamp.Before(history[j].Timestamp) })
+	start := r.now().Add(-r.opts.retention())
+	i := 0
+	for i < len(history) && !history[i].Timestamp.After(start) {
+		i++
+	}
+	r.data[kind] = history[i:]
+	return nil
+}
+
+// History returns the data of a kind received over a window, oldest first.
+// Data is shared, so it must not be modified.
+func (r *Rollup) History(kind string, window time.Duration) ([]livepprof.Data, error) {
+	end := r.now()
+	start, err := r.start(end, window)
+	if err != nil {
+		return nil, err
+	}
+	return r.history(kind, start, end), nil
+}
+
+func (r *Rollup) start(end time.Time, window time.Duration) (time.Time, error) {
+	if window <= 0 {
+		return time.Time{}, InvalidWindowError{Window: window}
+	}
+	if window > r.opts.retention() {
+		return time.Time{}, WindowTooLongError{Window: window, Retention: r.opts.retention()}
+	}
+	return end.Add(-window), nil
+}
+
+func (r *Rollup) history(kind string, start, end time.Time) []livepprof.Data {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var ret []livepprof.Data
+	for _, data := range r.data[kind] {
+		if data.Timestamp.After(start) && !data.Timestamp.After(end) {
+			ret = append(ret, data)
+		}
+	}
+	return ret
+}
+
+// Query aggregates the data of a kind over a window, which can't be longer
+// than the longest window, and returns the first limit locations.
+func (r *Rollup) Query(kind string, window time.Duration, limit int) (View, error) {
+	end := r.now()
+	start, err := r.start(end, window)
+	if err != nil {
+		return View{}, err
+	}
+	history := r.history(kind, start, end)
+
+	view := View{
+		Kind:       kind,
+		Window:     window,
+		Start:      start,
+		End:        end,
+		Heartbeats: len(history),
+	}
+	values := make(map[objfile.Location][]float64)
+	for _, data := range history {
+		// A location should be once in a Data, but if it is not,
+		// its values are summed, one value per heartbeat.
+		sums := make(map[objfile.Location]float64, len(data.Entries))
+		for _, entry := range data.Entries {
+			sums[entry.Key] += entry.Value
+		}
+		for key, sum := range sums {
+			values[key] = append(values[key], sum)
+		}
+	}
+	for key, v := range values {
+		view.Stats = append(view.Stats, stats(key, v, len(history)))
+	}
+	sort.Slice(view.Stats, func(i, j int) bool {
+		if view.Stats[i].Sum != view.Stats[j].Sum {
+			return view.Stats[i].Sum > view.Stats[j].Sum
+		}
+		return livepprof.LessLocation(view.Stats[i].Key, view.Stats[j].Key)
+	})
+	if limit >= 0 && len(view.Stats) > limit {
+		view.Stats = view.Stats[:limit]
+	}
+	return view, nil
+}
+
+// stats of a location, given its values in n heartbeats, the
+// heartbeats it is not in are zeroes.
+func stats(key objfile.Location, values []float64, n int) Stats {
+	s := Stats{Key: key, Count: len(values)}
+	for _, v := range values {
+		s.Sum += v
+	}
+	s.Mean = s.Sum / float64(n)
+
+	all := make([]float64, n)
+	copy(all[n-len(values):], values)
+	sort.Float64s(all)
+	// Nearest rank.
+	rank := int(math.Ceil(percentile * float64(n)))
+	s.P95 = all[rank-1]
+	return s
+}
+
+// ServeHTTP serves views in JSON. The kind parameter is required,
+// window is one of the windows, and defaults to the shortest one,
+// limit defaults to 20.
+func (r *Rollup) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	kind := req.FormValue("kind")
+	if kind == "" {
+		http.Error(w, "missing kind", http.StatusBadRequest)
+		return
+	}
+	window := r.opts.windows[0]
+	if s := req.FormValue("window"); s != "" {
+		d, err := time.ParseDuration(s)
+		if err != nil || !r.isWindow(d) {
+			http.Error(w, "invalid window, expected one of "+r.windowList(), http.StatusBadRequest)
+			return
+		}
+		window = d
+	}
+	limit := defaultLimit
+	if s := req.FormValue("limit"); s != "" {
+		l, err := strconv.Atoi(s)
+		if err != nil || l < 0 {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = l
+	}
+
+	view, err := r.Query(kind, window, limit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(view)
+}
+
+func (r *Rollup) isWindow(window time.Duration) bool {
+	for _, w := range r.opts.windows {
+		if w == window {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Rollup) windowList() string {
+	windows := make([]string, len(r.opts.windows))
+	for i, w := range r.opts.windows {
+		windows[i] = w.String()
+	}
+	return strings.Join(windows, ", ")
+}
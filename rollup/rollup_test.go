@@ -0,0 +1,138 @@
+// Live pprof is a Golang library to generate and use live profiles.
+// Copyright (C)  2018  Christian Mauduit <ufoot@ufoot.org>
+// Live pprof homepage: https://github.com/ufoot/livepprof
+// Contact author: ufoot@ufoot.org
+
+package rollup
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ufoot/livepprof"
+	"github.com/ufoot/livepprof/objfile"
+)
+
+var (
+	locA = objfile.Location{Function: "github.com/me/a.A", File: "a.go", Stack: "main.main/a.A"}
+	locB = objfile.Location{Function: "github.com/me/a.B", File: "a.go", Stack: "main.main/a.B"}
+)
+
+func TestQuery(t *testing.T) {
+	assert := assert.New(t)
+
+	r, err := New()
+	assert.Nil(err)
+	now := time.Date(2018, 1, 2, 3, 4, 5, 0, time.UTC)
+	r.now = func() time.Time { return now }
+
+	// One heartbeat per minute, for the last 10 minutes, B only
+	// in the last one, and a heartbeat too old to be kept.
+	assert.Nil(r.Write(livepprof.KindCPU, livepprof.Data{Timestamp: now.Add(-2 * time.Hour), Entries: []livepprof.Entry{{Key: locA, Value: 1000}}}))
+	for i := 9; i >= 0; i-- {
+		data := livepprof.Data{Timestamp: now.Add(-time.Duration(i) * time.Minute), Entries: []livepprof.Entry{{Key: locA, Value: float64(10 - i)}}}
+		if i == 0 {
+			data.Entries = append(data.Entries, livepprof.Entry{Key: locB, Value: 20})
+		}
+		assert.Nil(r.Write(livepprof.KindCPU, data))
+	}
+	assert.Nil(r.Write(livepprof.KindHeap, livepprof.Data{Timestamp: now, Entries: []livepprof.Entry{{Key: locA, Value: 1}}}))
+	assert.Equal([]string{livepprof.KindCPU, livepprof.KindHeap}, r.Kinds())
+
+	view, err := r.Query(livepprof.KindCPU, time.Minute, 10)
+	assert.Nil(err)
+	assert.Equal(View{
+		Kind:       livepprof.KindCPU,
+		Window:     time.Minute,
+		Start:      now.Add(-time.Minute),
+		End:        now,
+		Heartbeats: 1,
+		Stats: []Stats{
+			{Key: locB, Sum: 20, Mean: 20, P95: 20, Count: 1},
+			{Key: locA, Sum: 10, Mean: 10, P95: 10, Count: 1},
+		},
+	}, view)
+
+	view, err = r.Query(livepprof.KindCPU, time.Hour, 10)
+	assert.Nil(err)
+	assert.Equal(10, view.Heartbeats)
+	assert.Equal([]Stats{
+		{Key: locA, Sum: 55, Mean: 5.5, P95: 10, Count: 10},
+		{Key: locB, Sum: 20, Mean: 2, P95: 20, Count: 1},
+	}, view.Stats)
+
+	view, err = r.Query(livepprof.KindCPU, 5*time.Minute, 1)
+	assert.Nil(err)
+	assert.Equal(5, view.Heartbeats)
+	assert.Equal([]Stats{{Key: locA, Sum: 40, Mean: 8, P95: 10, Count: 5}}, view.Stats)
+
+	history, err := r.History(livepprof.KindCPU, 2*time.Minute)
+	assert.Nil(err)
+	assert.Len(history, 2)
+
+	_, err = r.Query(livepprof.KindCPU, 2*time.Hour, 10)
+	assert.Equal(WindowTooLongError{Window: 2 * time.Hour, Retention: time.Hour}, err)
+	_, err = r.Query(livepprof.KindCPU, 0, 10)
+	assert.Equal(InvalidWindowError{}, err)
+}
+
+func TestQueryDuplicates(t *testing.T) {
+	assert := assert.New(t)
+
+	r, err := New()
+	assert.Nil(err)
+	now := time.Date(2018, 1, 2, 3, 4, 5, 0, time.UTC)
+	r.now = func() time.Time { return now }
+
+	// The same location twice in a single heartbeat.
+	assert.Nil(r.Write(livepprof.KindCPU, livepprof.Data{Timestamp: now, Entries: []livepprof.Entry{{Key: locA, Value: 1}, {Key: locA, Value: 2}}}))
+	view, err := r.Query(livepprof.KindCPU, time.Minute, 10)
+	assert.Nil(err)
+	assert.Equal([]Stats{{Key: locA, Sum: 3, Mean: 3, P95: 3, Count: 1}}, view.Stats)
+}
+
+func TestOptions(t *testing.T) {
+	assert := assert.New(t)
+
+	r, err := New(WithWindows(time.Hour, 10*time.Second), WithKinds(livepprof.KindHeap))
+	assert.Nil(err)
+	assert.Equal([]time.Duration{10 * time.Second, time.Hour}, r.Windows())
+	assert.Nil(r.Write(livepprof.KindCPU, livepprof.Data{Timestamp: time.Now()}))
+	assert.Nil(r.Write(livepprof.KindHeap, livepprof.Data{Timestamp: time.Now()}))
+	assert.Equal([]string{livepprof.KindHeap}, r.Kinds())
+
+	_, err = New(WithWindows())
+	assert.NotNil(err)
+	_, err = New(WithWindows(-time.Second))
+	assert.NotNil(err)
+}
+
+func TestServeHTTP(t *testing.T) {
+	assert := assert.New(t)
+
+	r, err := New()
+	assert.Nil(err)
+	now := time.Now()
+	r.now = func() time.Time { return now }
+	assert.Nil(r.Write(livepprof.KindCPU, livepprof.Data{Timestamp: now, Entries: []livepprof.Entry{{Key: locA, Value: 1}, {Key: locB, Value: 2}}}))
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/rollup?kind=cpu&window=5m&limit=1", nil))
+	assert.Equal(http.StatusOK, w.Code)
+	assert.Equal("application/json", w.Result().Header.Get("Content-Type"))
+	var view View
+	assert.Nil(json.NewDecoder(w.Body).Decode(&view))
+	assert.Equal(5*time.Minute, view.Window)
+	assert.Equal([]Stats{{Key: locB, Sum: 2, Mean: 2, P95: 2, Count: 1}}, view.Stats)
+
+	for _, target := range []string{"/rollup", "/rollup?kind=cpu&window=2m", "/rollup?kind=cpu&limit=x"} {
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", target, nil))
+		assert.Equal(http.StatusBadRequest, w.Code, target)
+	}
+}
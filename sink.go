@@ -0,0 +1,99 @@
+// Live pprof is a Golang library to generate and use live profiles.
+// Copyright (C)  2018  Christian Mauduit <ufoot@ufoot.org>
+// Live pprof homepage: https://github.com/ufoot/livepprof
+// Contact author: ufoot@ufoot.org
+
+package livepprof
+
+// Sink receives data as it is collected, this is an alternative to
+// reading channels. Several sinks can be used at the same time, each
+// one of them receives all data, and is called from its own goroutine.
+type Sink interface {
+	// Write data of a given kind (KindCPU, KindHeap...).
+	// Data is shared among sinks, so it must not be modified.
+	Write(kind string, data Data) error
+}
+
+// SinkError when a sink fails to write data.
+type SinkError struct {
+	// Kind of data which could not be written.
+	Kind string
+	// Err returned by the sink.
+	Err error
+}
+
+// Error string.
+func (e SinkError) Error() string {
+	return "sink error on " + e.Kind + ": " + e.Err.Error()
+}
+
+// SinkFullError when a sink is too slow and data has been dropped.
+type SinkFullError struct {
+	// Kind of data which has been dropped.
+	Kind string
+}
+
+// Error string.
+func (e SinkFullError) Error() string {
+	return "sink full, dropped " + e.Kind
+}
+
+type sinkItem struct {
+	kind string
+	data Data
+}
+
+// sinkQueue buffers data for a sink, so that a slow sink does not
+// slow down collection, nor the other sinks.
+type sinkQueue struct {
+	sink  Sink
+	queue chan sinkItem
+}
+
+func (lp *LP) runSink(sq *sinkQueue) {
+	defer lp.sinkWg.Done()
+
+	for item := range sq.queue {
+		if err := sq.sink.Write(item.kind, item.data); err != nil {
+			lp.handleErr(SinkError{Kind: item.kind, Err: err})
+		}
+	}
+}
+
+func (lp *LP) startSinks() {
+	for _, sink := range lp.opts.sinks {
+		sq := &sinkQueue{
+			sink:  sink,
+			queue: make(chan sinkItem, lp.opts.sinkBuffer),
+		}
+		lp.sinks = append(lp.sinks, sq)
+		lp.sinkWg.Add(1)
+		go lp.runSink(sq)
+	}
+}
+
+// closeSinks waits until all the sinks have written their pending data.
+func (lp *LP) closeSinks() {
+	lp.sinkMu.Lock()
+	defer lp.sinkMu.Unlock()
+
+	for _, sq := range lp.sinks {
+		close(sq.queue)
+	}
+	lp.sinks = nil
+	lp.sinkWg.Wait()
+}
+
+// publish sends data to all sinks, never blocking.
+func (lp *LP) publish(kind string, data Data) {
+	lp.sinkMu.RLock()
+	defer lp.sinkMu.RUnlock()
+
+	for _, sq := range lp.sinks {
+		select {
+		case sq.queue <- sinkItem{kind: kind, data: data}:
+		default:
+			lp.handleErr(SinkFullError{Kind: kind})
+		}
+	}
+}
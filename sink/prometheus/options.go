@@ -0,0 +1,88 @@
+// Live pprof is a Golang library to generate and use live profiles.
+// Copyright (C)  2018  Christian Mauduit <ufoot@ufoot.org>
+// Live pprof homepage: https://github.com/ufoot/livepprof
+// Contact author: ufoot@ufoot.org
+
+package prometheus
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+)
+
+const (
+	// LabelFunction is the label containing the function name.
+	LabelFunction = "function"
+	// LabelFile is the label containing the file name.
+	LabelFile = "file"
+	// LabelStack is the label containing the call stack.
+	LabelStack = "stack"
+)
+
+const (
+	// defaultPrefix for metric names.
+	defaultPrefix = "livepprof"
+	// defaultTTL after which data is considered outdated.
+	defaultTTL = 5 * time.Minute
+)
+
+var prefixRegexp = regexp.MustCompile("^[a-zA-Z_:][a-zA-Z0-9_:]*$")
+
+type opts struct {
+	prefix string
+	labels []string
+	ttl    time.Duration
+}
+
+var defaultOpts = opts{
+	prefix: defaultPrefix,
+	labels: []string{LabelFunction, LabelFile, LabelStack},
+	ttl:    defaultTTL,
+}
+
+// Option passed when creating the handler.
+type Option func(o *opts) error
+
+// WithPrefix allows a custom metric prefix to be used. Default is "livepprof",
+// metrics are then called "livepprof_cpu", "livepprof_heap", and so on.
+func WithPrefix(prefix string) Option {
+	return func(o *opts) error {
+		if !prefixRegexp.MatchString(prefix) {
+			return fmt.Errorf("invalid prefix: %s", prefix)
+		}
+		o.prefix = prefix
+		return nil
+	}
+}
+
+// WithLabels allows you to choose which location labels are exported. Default is
+// LabelFunction, LabelFile and LabelStack. LabelStack has the highest cardinality,
+// dropping it is the first thing to do if there are too many series. Entries which
+// end up with the same labels are summed.
+func WithLabels(labels ...string) Option {
+	return func(o *opts) error {
+		for _, label := range labels {
+			switch label {
+			case LabelFunction, LabelFile, LabelStack:
+			default:
+				return fmt.Errorf("invalid label: %s", label)
+			}
+		}
+		o.labels = append([]string(nil), labels...)
+		return nil
+	}
+}
+
+// WithTTL allows a custom time to live to be used. Default is 5 minutes.
+// Data which has not been updated for that long is not exported any more,
+// typically because the profiler has been stopped.
+func WithTTL(ttl time.Duration) Option {
+	return func(o *opts) error {
+		if ttl <= 0 {
+			return fmt.Errorf("invalid TTL: %s", ttl.String())
+		}
+		o.ttl = ttl
+		return nil
+	}
+}
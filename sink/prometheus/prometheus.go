@@ -0,0 +1,133 @@
+// Live pprof is a Golang library to generate and use live profiles.
+// Copyright (C)  2018  Christian Mauduit <ufoot@ufoot.org>
+// Live pprof homepage: https://github.com/ufoot/livepprof
+// Contact author: ufoot@ufoot.org
+
+package prometheus
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ufoot/livepprof"
+)
+
+// Prometheus is a sink which keeps the latest data for each kind of
+// profile, and serves it over HTTP in Prometheus text exposition format.
+// Scraping does not trigger any profiling, it only reads what the live
+// profiler last reported. Since only the latest data is exported,
+// locations which disappear between heartbeats are not exported any more,
+// and Prometheus marks them stale, instead of keeping ghost series.
+type Prometheus struct {
+	opts opts
+	mu   sync.RWMutex
+	last map[string]latest
+	now  func() time.Time
+}
+
+type latest struct {
+	data     livepprof.Data
+	received time.Time
+}
+
+var _ livepprof.Sink = &Prometheus{}
+var _ http.Handler = &Prometheus{}
+
+// New prometheus sink and handler.
+func New(options ...Option) (*Prometheus, error) {
+	opts := defaultOpts
+	for _, opt := range options {
+		if err := opt(&opts); err != nil {
+			return nil, err
+		}
+	}
+	return &Prometheus{
+		opts: opts,
+		last: make(map[string]latest),
+		now:  time.Now,
+	}, nil
+}
+
+// Write stores data, replacing the previous data of the same kind.
+func (p *Prometheus) Write(kind string, data livepprof.Data) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.last[kind] = latest{data: data, received: p.now()}
+	return nil
+}
+
+var labelReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func (p *Prometheus) labels(entry *livepprof.Entry) string {
+	labels := make([]string, 0, len(p.opts.labels))
+	for _, label := range p.opts.labels {
+		var value string
+		switch label {
+		case LabelFunction:
+			value = entry.Key.Function
+		case LabelFile:
+			value = entry.Key.File
+		case LabelStack:
+			value = entry.Key.Stack
+		}
+		labels = append(labels, label+`="`+labelReplacer.Replace(value)+`"`)
+	}
+	return "{" + strings.Join(labels, ",") + "}"
+}
+
+func (p *Prometheus) writeKind(buf *bytes.Buffer, kind string, data *livepprof.Data) {
+	name := p.opts.prefix + "_" + kind
+	fmt.Fprintf(buf, "# HELP %s Live profile data for %s, by code location.\n", name, kind)
+	fmt.Fprintf(buf, "# TYPE %s gauge\n", name)
+
+	// Entries are unique, but some labels may be dropped, so sum them.
+	values := make(map[string]float64, len(data.Entries))
+	order := make([]string, 0, len(data.Entries))
+	for i := range data.Entries {
+		labels := p.labels(&data.Entries[i])
+		if _, ok := values[labels]; !ok {
+			order = append(order, labels)
+		}
+		values[labels] += data.Entries[i].Value
+	}
+	for _, labels := range order {
+		buf.WriteString(name + labels + " " + strconv.FormatFloat(values[labels], 'g', -1, 64) + "\n")
+	}
+}
+
+// Expose writes all metrics which are not outdated, in text exposition format.
+func (p *Prometheus) Expose() []byte {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.now()
+	kinds := make([]string, 0, len(p.last))
+	for kind, l := range p.last {
+		if now.Sub(l.received) > p.opts.ttl {
+			delete(p.last, kind)
+			continue
+		}
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+
+	var buf bytes.Buffer
+	for _, kind := range kinds {
+		data := p.last[kind].data
+		p.writeKind(&buf, kind, &data)
+	}
+	return buf.Bytes()
+}
+
+// ServeHTTP serves metrics, typically on /metrics.
+func (p *Prometheus) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_, _ = w.Write(p.Expose())
+}
@@ -0,0 +1,94 @@
+// Live pprof is a Golang library to generate and use live profiles.
+// Copyright (C)  2018  Christian Mauduit <ufoot@ufoot.org>
+// Live pprof homepage: https://github.com/ufoot/livepprof
+// Contact author: ufoot@ufoot.org
+
+package prometheus
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ufoot/livepprof"
+	"github.com/ufoot/livepprof/objfile"
+)
+
+func entry(function, stack string, value float64) livepprof.Entry {
+	return livepprof.Entry{
+		Key: objfile.Location{
+			Function: function,
+			File:     "/src/a.go",
+			Stack:    stack,
+		},
+		Value: value,
+	}
+}
+
+func TestServeHTTP(t *testing.T) {
+	assert := assert.New(t)
+
+	p, err := New()
+	assert.Nil(err)
+	now := time.Now()
+	p.now = func() time.Time { return now }
+
+	assert.Nil(p.Write(livepprof.KindHeap, livepprof.Data{Entries: []livepprof.Entry{
+		entry("f1", "main.main/f1", 100),
+		entry(`f"2`, "main.main/f2", 200),
+	}}))
+	assert.Nil(p.Write(livepprof.KindCPU, livepprof.Data{Entries: []livepprof.Entry{
+		entry("f3", "main.main/f3", 1.5),
+	}}))
+
+	w := httptest.NewRecorder()
+	p.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	body, err := ioutil.ReadAll(w.Result().Body)
+	assert.Nil(err)
+	assert.Equal("text/plain; version=0.0.4; charset=utf-8", w.Result().Header.Get("Content-Type"))
+	assert.Equal(`# HELP livepprof_cpu Live profile data for cpu, by code location.
+# TYPE livepprof_cpu gauge
+livepprof_cpu{function="f3",file="/src/a.go",stack="main.main/f3"} 1.5
+# HELP livepprof_heap Live profile data for heap, by code location.
+# TYPE livepprof_heap gauge
+livepprof_heap{function="f1",file="/src/a.go",stack="main.main/f1"} 100
+livepprof_heap{function="f\"2",file="/src/a.go",stack="main.main/f2"} 200
+`, string(body))
+
+	// f1 disappears, it must not be exported any more.
+	now = now.Add(time.Minute)
+	assert.Nil(p.Write(livepprof.KindHeap, livepprof.Data{Entries: []livepprof.Entry{
+		entry(`f"2`, "main.main/f2", 300),
+	}}))
+	// cpu is outdated, it must not be exported any more.
+	now = now.Add(defaultTTL)
+	assert.Equal(`# HELP livepprof_heap Live profile data for heap, by code location.
+# TYPE livepprof_heap gauge
+livepprof_heap{function="f\"2",file="/src/a.go",stack="main.main/f2"} 300
+`, string(p.Expose()))
+}
+
+func TestLabels(t *testing.T) {
+	assert := assert.New(t)
+
+	p, err := New(WithPrefix("test"), WithLabels(LabelFile))
+	assert.Nil(err)
+	assert.Nil(p.Write(livepprof.KindCPU, livepprof.Data{Entries: []livepprof.Entry{
+		entry("f1", "main.main/f1", 1),
+		entry("f2", "main.main/f2", 2),
+	}}))
+	assert.Equal(`# HELP test_cpu Live profile data for cpu, by code location.
+# TYPE test_cpu gauge
+test_cpu{file="/src/a.go"} 3
+`, string(p.Expose()))
+
+	_, err = New(WithPrefix("not-valid"))
+	assert.NotNil(err)
+	_, err = New(WithLabels("nothing"))
+	assert.NotNil(err)
+	_, err = New(WithTTL(0))
+	assert.NotNil(err)
+}
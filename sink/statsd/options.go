@@ -0,0 +1,124 @@
+// Live pprof is a Golang library to generate and use live profiles.
+// Copyright (C)  2018  Christian Mauduit <ufoot@ufoot.org>
+// Live pprof homepage: https://github.com/ufoot/livepprof
+// Contact author: ufoot@ufoot.org
+
+package statsd
+
+import (
+	"fmt"
+)
+
+const (
+	// TagFunction is the tag containing the function name.
+	TagFunction = "function"
+	// TagFile is the tag containing the file name.
+	TagFile = "file"
+	// TagStack is the tag containing the call stack.
+	TagStack = "stack"
+	// TagKind is the tag containing the kind of profile, always set.
+	TagKind = "kind"
+)
+
+const (
+	// defaultPrefix for metric names.
+	defaultPrefix = "livepprof"
+	// defaultMTU is the max packet size, this is what DogStatsD
+	// recommends for UDP, it avoids fragmentation on most networks.
+	defaultMTU = 1432
+	// defaultMaxTagLen is the max length of a tag value, longer values are truncated.
+	defaultMaxTagLen = 200
+)
+
+type opts struct {
+	prefix     string
+	tags       []string
+	globalTags []string
+	maxEntries int
+	maxTagLen  int
+	mtu        int
+}
+
+var defaultOpts = opts{
+	prefix:    defaultPrefix,
+	tags:      []string{TagFunction, TagFile, TagStack},
+	maxTagLen: defaultMaxTagLen,
+	mtu:       defaultMTU,
+}
+
+// Option passed when creating the sink.
+type Option func(o *opts) error
+
+// WithPrefix allows a custom metric prefix to be used. Default is "livepprof",
+// metrics are then called "livepprof.cpu", "livepprof.heap", and so on.
+func WithPrefix(prefix string) Option {
+	return func(o *opts) error {
+		if prefix == "" {
+			return fmt.Errorf("invalid empty prefix")
+		}
+		o.prefix = prefix
+		return nil
+	}
+}
+
+// WithTags allows you to choose which location tags are sent. Default is
+// TagFunction, TagFile and TagStack. TagStack has the highest cardinality,
+// dropping it is the first thing to do if there are too many series.
+// TagKind is always sent.
+func WithTags(tags ...string) Option {
+	return func(o *opts) error {
+		for _, tag := range tags {
+			switch tag {
+			case TagFunction, TagFile, TagStack:
+			default:
+				return fmt.Errorf("invalid tag: %s", tag)
+			}
+		}
+		o.tags = append([]string(nil), tags...)
+		return nil
+	}
+}
+
+// WithGlobalTags adds static tags to every metric, eg "env:prod".
+func WithGlobalTags(tags ...string) Option {
+	return func(o *opts) error {
+		o.globalTags = append(append([]string(nil), o.globalTags...), tags...)
+		return nil
+	}
+}
+
+// WithMaxEntries limits the number of entries sent for each Data,
+// only the first, greater ones, are sent. Default is 0, no limit.
+func WithMaxEntries(maxEntries int) Option {
+	return func(o *opts) error {
+		if maxEntries < 0 {
+			return fmt.Errorf("invalid max entries: %d", maxEntries)
+		}
+		o.maxEntries = maxEntries
+		return nil
+	}
+}
+
+// WithMaxTagLen allows a custom max tag value length, in characters. Default is 200.
+// Longer values are truncated, this typically happens with deep stacks.
+func WithMaxTagLen(maxTagLen int) Option {
+	return func(o *opts) error {
+		if maxTagLen <= 0 {
+			return fmt.Errorf("invalid max tag len: %d", maxTagLen)
+		}
+		o.maxTagLen = maxTagLen
+		return nil
+	}
+}
+
+// WithMTU allows a custom max packet size to be used. Default is 1432.
+// Metrics are batched in packets which do not exceed it.
+func WithMTU(mtu int) Option {
+	return func(o *opts) error {
+		if mtu <= 0 {
+			return fmt.Errorf("invalid MTU: %d", mtu)
+		}
+		o.mtu = mtu
+		return nil
+	}
+}
@@ -0,0 +1,124 @@
+// Live pprof is a Golang library to generate and use live profiles.
+// Copyright (C)  2018  Christian Mauduit <ufoot@ufoot.org>
+// Live pprof homepage: https://github.com/ufoot/livepprof
+// Contact author: ufoot@ufoot.org
+
+package statsd
+
+import (
+	"bytes"
+	"net"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ufoot/livepprof"
+)
+
+// Statsd is a sink which sends entries as gauges, in DogStatsD format,
+// over UDP. Plain StatsD servers ignore tags, so they would only
+// get the sum for each kind of profile.
+type Statsd struct {
+	conn net.Conn
+	opts opts
+}
+
+var _ livepprof.Sink = &Statsd{}
+
+// New statsd sink, sending metrics to addr, typically "localhost:8125".
+func New(addr string, options ...Option) (*Statsd, error) {
+	opts := defaultOpts
+	for _, opt := range options {
+		if err := opt(&opts); err != nil {
+			return nil, err
+		}
+	}
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return &Statsd{conn: conn, opts: opts}, nil
+}
+
+var tagReplacer = strings.NewReplacer(",", "_", "|", "_", "#", "_", "\n", "_", " ", "_")
+
+// tag formats a key:value tag, removing chars which have a meaning
+// in DogStatsD protocol, and truncating values which are too long.
+// Values are truncated on runes, so that tags stay valid UTF-8.
+func (s *Statsd) tag(key, value string) string {
+	value = tagReplacer.Replace(value)
+	if utf8.RuneCountInString(value) > s.opts.maxTagLen {
+		value = string([]rune(value)[:s.opts.maxTagLen])
+	}
+	return key + ":" + value
+}
+
+func (s *Statsd) tags(kind string, entry *livepprof.Entry) string {
+	tags := make([]string, 0, len(s.opts.globalTags)+len(s.opts.tags)+1)
+	tags = append(tags, s.opts.globalTags...)
+	tags = append(tags, s.tag(TagKind, kind))
+	for _, tag := range s.opts.tags {
+		switch tag {
+		case TagFunction:
+			tags = append(tags, s.tag(TagFunction, entry.Key.Function))
+		case TagFile:
+			tags = append(tags, s.tag(TagFile, entry.Key.File))
+		case TagStack:
+			tags = append(tags, s.tag(TagStack, entry.Key.Stack))
+		}
+	}
+	return strings.Join(tags, ",")
+}
+
+// Write sends one gauge per set of tags, batched in packets smaller than the MTU.
+// A single metric bigger than the MTU is still sent, alone. Gauges are last
+// write wins, so entries which end up with the same tags, because some of
+// them are dropped or truncated, are summed.
+func (s *Statsd) Write(kind string, data livepprof.Data) error {
+	entries := data.Entries
+	if s.opts.maxEntries > 0 && len(entries) > s.opts.maxEntries {
+		entries = entries[:s.opts.maxEntries]
+	}
+
+	values := make(map[string]float64, len(entries))
+	order := make([]string, 0, len(entries))
+	for i := range entries {
+		tags := s.tags(kind, &entries[i])
+		if _, ok := values[tags]; !ok {
+			order = append(order, tags)
+		}
+		values[tags] += entries[i].Value
+	}
+
+	var buf bytes.Buffer
+	flush := func() error {
+		if buf.Len() == 0 {
+			return nil
+		}
+		_, err := s.conn.Write(buf.Bytes())
+		buf.Reset()
+		return err
+	}
+
+	for _, tags := range order {
+		line := s.opts.prefix + "." + kind + ":" +
+			strconv.FormatFloat(values[tags], 'f', -1, 64) +
+			"|g|#" + tags
+		if buf.Len() > 0 && buf.Len()+1+len(line) > s.opts.mtu {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+		if buf.Len() > 0 {
+			buf.WriteByte('\n')
+		}
+		buf.WriteString(line)
+	}
+
+	return flush()
+}
+
+// Close the sink.
+func (s *Statsd) Close() error {
+	return s.conn.Close()
+}
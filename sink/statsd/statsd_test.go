@@ -0,0 +1,129 @@
+// Live pprof is a Golang library to generate and use live profiles.
+// Copyright (C)  2018  Christian Mauduit <ufoot@ufoot.org>
+// Live pprof homepage: https://github.com/ufoot/livepprof
+// Contact author: ufoot@ufoot.org
+
+package statsd
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ufoot/livepprof"
+	"github.com/ufoot/livepprof/objfile"
+)
+
+func testData(n int) livepprof.Data {
+	data := livepprof.Data{Timestamp: time.Now()}
+	for i := 0; i < n; i++ {
+		data.Entries = append(data.Entries, livepprof.Entry{
+			Key: objfile.Location{
+				Function: fmt.Sprintf("github.com/me/mypackage.f%d", i),
+				File:     "/src/github.com/me/mypackage/a,b.go",
+				Stack:    fmt.Sprintf("main.main/mypackage.f%d", i),
+			},
+			Value: float64(n - i),
+		})
+	}
+	return data
+}
+
+func readPackets(t *testing.T, pc net.PacketConn) []string {
+	var ret []string
+	buf := make([]byte, 65536)
+	for {
+		if err := pc.SetReadDeadline(time.Now().Add(time.Second / 10)); err != nil {
+			t.Fatal(err)
+		}
+		n, _, err := pc.ReadFrom(buf)
+		if err != nil {
+			return ret
+		}
+		ret = append(ret, string(buf[:n]))
+	}
+}
+
+func TestWrite(t *testing.T) {
+	assert := assert.New(t)
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.Nil(err)
+	defer pc.Close()
+
+	s, err := New(pc.LocalAddr().String(),
+		WithPrefix("test"),
+		WithGlobalTags("env:test"),
+		WithMTU(512),
+	)
+	assert.Nil(err)
+	defer s.Close()
+
+	assert.Nil(s.Write(livepprof.KindCPU, testData(20)))
+	packets := readPackets(t, pc)
+	assert.True(len(packets) > 1, "data should be split in several packets")
+	var lines []string
+	for _, packet := range packets {
+		assert.True(len(packet) <= 512, fmt.Sprintf("packet too big: %d", len(packet)))
+		lines = append(lines, strings.Split(packet, "\n")...)
+	}
+	assert.Equal(20, len(lines))
+	assert.Equal("test.cpu:20|g|#env:test,kind:cpu,function:github.com/me/mypackage.f0,"+
+		"file:/src/github.com/me/mypackage/a_b.go,stack:main.main/mypackage.f0", lines[0])
+	assert.Equal("test.cpu:1|g|#env:test,kind:cpu,function:github.com/me/mypackage.f19,"+
+		"file:/src/github.com/me/mypackage/a_b.go,stack:main.main/mypackage.f19", lines[19])
+}
+
+func TestWriteLimits(t *testing.T) {
+	assert := assert.New(t)
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.Nil(err)
+	defer pc.Close()
+
+	s, err := New(pc.LocalAddr().String(),
+		WithTags(TagFunction),
+		WithMaxEntries(3),
+		WithMaxTagLen(10),
+	)
+	assert.Nil(err)
+	defer s.Close()
+
+	assert.Nil(s.Write(livepprof.KindHeap, testData(20)))
+	packets := readPackets(t, pc)
+	assert.Equal(1, len(packets))
+	// Tags are the same once truncated, values are summed.
+	assert.Equal("livepprof.heap:57|g|#kind:heap,function:github.com", packets[0])
+}
+
+func TestTagUTF8(t *testing.T) {
+	assert := assert.New(t)
+
+	s := &Statsd{opts: defaultOpts}
+	s.opts.maxTagLen = 10
+	tag := s.tag(TagFunction, "github.com/été/paquet.Fonction")
+	assert.True(utf8.ValidString(tag))
+	assert.Equal("function:github.com", tag)
+	tag = s.tag(TagFunction, "パッケージ.関数.ループ処理")
+	assert.True(utf8.ValidString(tag))
+	assert.Equal("function:パッケージ.関数.ル", tag)
+}
+
+func TestOptions(t *testing.T) {
+	assert := assert.New(t)
+
+	o := defaultOpts
+	assert.NotNil(WithPrefix("")(&o))
+	assert.NotNil(WithTags("nothing")(&o))
+	assert.NotNil(WithMaxEntries(-1)(&o))
+	assert.NotNil(WithMaxTagLen(0)(&o))
+	assert.NotNil(WithMTU(0)(&o))
+	assert.Nil(WithGlobalTags("a:b")(&o))
+	assert.Equal([]string{"a:b"}, o.globalTags)
+	assert.Nil(defaultOpts.globalTags)
+}
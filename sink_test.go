@@ -0,0 +1,100 @@
+// Live pprof is a Golang library to generate and use live profiles.
+// Copyright (C)  2018  Christian Mauduit <ufoot@ufoot.org>
+// Live pprof homepage: https://github.com/ufoot/livepprof
+// Contact author: ufoot@ufoot.org
+
+package livepprof
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testSink struct {
+	mu    sync.Mutex
+	kinds map[string]int
+	err   error
+	block chan struct{}
+}
+
+func (ts *testSink) Write(kind string, data Data) error {
+	if ts.block != nil {
+		<-ts.block
+	}
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	if ts.kinds == nil {
+		ts.kinds = make(map[string]int)
+	}
+	ts.kinds[kind]++
+	return ts.err
+}
+
+func (ts *testSink) count(kind string) int {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	return ts.kinds[kind]
+}
+
+func TestSink(t *testing.T) {
+	assert := assert.New(t)
+
+	var errMu sync.Mutex
+	var errs []error
+	errHandler := func(err error) {
+		errMu.Lock()
+		defer errMu.Unlock()
+		errs = append(errs, err)
+	}
+
+	sink1 := &testSink{}
+	sink2 := &testSink{err: errors.New("sink2 failed")}
+	sink3 := &testSink{block: make(chan struct{})}
+
+	lp, err := New(
+		WithFilter("livepprof"),
+		WithErrorHandler(errHandler),
+		WithDelay(time.Second/10),
+		WithSink(sink1),
+		WithSink(sink2),
+		WithSink(sink3),
+		WithSinkBuffer(1),
+	)
+	assert.Nil(err)
+	assert.NotNil(lp)
+
+	// Nobody reads channels, this must not block sinks.
+	time.Sleep(time.Second)
+	close(sink3.block)
+	lp.Close()
+
+	for _, kind := range []string{KindCPU, KindHeap, KindGoroutine} {
+		assert.True(sink1.count(kind) > 1, kind)
+		// Queues are not blocking, so counts may differ slightly.
+		assert.True(sink2.count(kind) > 1, kind)
+		assert.True(sink3.count(kind) < sink1.count(kind), kind)
+	}
+
+	errMu.Lock()
+	defer errMu.Unlock()
+	var sinkErrs, sinkFullErrs int
+	for _, err := range errs {
+		switch e := err.(type) {
+		case SinkError:
+			assert.Equal("sink2 failed", e.Err.Error())
+			sinkErrs++
+		case SinkFullError:
+			sinkFullErrs++
+		default:
+			assert.Nil(err)
+		}
+	}
+	assert.True(sinkErrs > 0)
+	assert.True(sinkFullErrs > 0)
+}
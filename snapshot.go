@@ -0,0 +1,55 @@
+// Live pprof is a Golang library to generate and use live profiles.
+// Copyright (C)  2018  Christian Mauduit <ufoot@ufoot.org>
+// Live pprof homepage: https://github.com/ufoot/livepprof
+// Contact author: ufoot@ufoot.org
+
+package livepprof
+
+import (
+	"context"
+	"time"
+
+	"github.com/ufoot/livepprof/collector"
+	"github.com/ufoot/livepprof/collector/cpu"
+)
+
+// ClosedError when the profiler is closed.
+type ClosedError struct{}
+
+// Error string.
+func (e ClosedError) Error() string {
+	return "profiler closed"
+}
+
+// SnapshotCPU profiles the CPU for a given duration, right now, and
+// returns the data. If the background loop is profiling the CPU,
+// it waits for it to be done first, as there can only be one CPU
+// profile at a time. Data is not sent to channels nor sinks.
+func (lp *LP) SnapshotCPU(ctx context.Context, duration time.Duration) (Data, error) {
+	return lp.snapshot(ctx, KindCPU, cpu.New(lp.opts.filter, duration,
+		append(lp.collectorOptions(), collector.WithBusyPolicy(lp.opts.busyPolicy))...))
+}
+
+// SnapshotHeap profiles the heap, right now, and returns the data.
+// With a cumulative sample type (alloc_space, alloc_objects), values
+// are computed since the previous snapshot, so the first one is empty.
+// Data is not sent to channels nor sinks.
+func (lp *LP) SnapshotHeap(ctx context.Context) (Data, error) {
+	return lp.snapshot(ctx, KindHeap, lp.heapSnapshot)
+}
+
+func (lp *LP) snapshot(ctx context.Context, kind string, c collector.ContextCollector) (Data, error) {
+	lp.mu.RLock()
+	closed := lp.streams == nil
+	lp.mu.RUnlock()
+	if closed {
+		return Data{}, ClosedError{}
+	}
+
+	now := time.Now()
+	rawData, err := c.CollectContext(ctx)
+	if err != nil {
+		return Data{}, err
+	}
+	return lp.buildData(kind, now, rawData), nil
+}
@@ -0,0 +1,121 @@
+// Live pprof is a Golang library to generate and use live profiles.
+// Copyright (C)  2018  Christian Mauduit <ufoot@ufoot.org>
+// Live pprof homepage: https://github.com/ufoot/livepprof
+// Contact author: ufoot@ufoot.org
+
+package livepprof
+
+import (
+	"context"
+	"math"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ufoot/livepprof/objfile"
+)
+
+func spinner(exit <-chan struct{}) float64 {
+	var j float64
+	for {
+		select {
+		case <-exit:
+			return j
+		default:
+			for i := 0; i < 1e4; i++ {
+				j += math.Sqrt(float64(i))
+			}
+		}
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	assert := assert.New(t)
+
+	lp, err := New(
+		WithFilter("livepprof"),
+		WithErrorHandler(func(err error) { assert.Nil(err) }),
+		WithDelay(time.Second/10),
+		// Background CPU collections wait for snapshots, which are longer.
+		WithTimeout(10*time.Second),
+	)
+	assert.Nil(err)
+
+	exit := make(chan struct{})
+	done := make(chan float64)
+	go func() { done <- spinner(exit) }()
+
+	// Several CPU snapshots at once, while the background loop runs,
+	// they must wait for each other instead of failing.
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			data, err := lp.SnapshotCPU(context.Background(), time.Second/5)
+			assert.Nil(err)
+			assert.True(len(data.Entries) > 0)
+			assert.True(data.Cores > 0)
+		}()
+	}
+	wg.Wait()
+	close(exit)
+	t.Logf("spinner: %0.1f", <-done)
+
+	buf := allocator1(1e6)
+	// Heap data is as of the last GC.
+	runtime.GC()
+	data, err := lp.SnapshotHeap(context.Background())
+	assert.Nil(err)
+	assert.True(len(data.Entries) > 0)
+	assert.Equal(byte(0), buf[1])
+
+	stats := lp.CacheStats()
+	assert.True(stats.Hits+stats.Misses > 0)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = lp.SnapshotCPU(ctx, time.Second)
+	assert.Equal(context.Canceled, err)
+
+	_, err = lp.SnapshotCPU(context.Background(), 0)
+	assert.NotNil(err)
+
+	lp.Close()
+	_, err = lp.SnapshotHeap(context.Background())
+	assert.Equal(ClosedError{}, err)
+}
+
+func TestSnapshotLeafFilter(t *testing.T) {
+	assert := assert.New(t)
+
+	// Record every allocation, else buf might not be sampled at all.
+	defer func(rate int) { runtime.MemProfileRate = rate }(runtime.MemProfileRate)
+	runtime.MemProfileRate = 1
+
+	pattern, err := objfile.MatchRegexp(objfile.FieldFunction, `livepprof\.allocator[0-9]$`)
+	assert.Nil(err)
+	lp, err := New(
+		WithLeafFilter(&objfile.Filter{Include: []objfile.Pattern{pattern}}),
+		WithErrorHandler(func(err error) { assert.Nil(err) }),
+		WithLimit(1000),
+	)
+	assert.Nil(err)
+	defer lp.Close()
+
+	buf := allocator2(1e6)
+	runtime.GC()
+	data, err := lp.SnapshotHeap(context.Background())
+	assert.Nil(err)
+	found := false
+	for _, entry := range data.Entries {
+		if entry.Key.Function == "github.com/ufoot/livepprof.allocator2" {
+			found = true
+		}
+	}
+	assert.True(found, "allocator2 should be a leaf")
+	assert.Equal(byte(0), buf[1])
+}